@@ -1,6 +1,8 @@
 package errs
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"runtime/debug"
 	"time"
@@ -14,7 +16,14 @@ type Err interface {
 	UserMessage() string
 	SetUserMessage(userMessage string)
 	InternalInfo() Info
+	AllInfo() Info
+	HTTPStatus() int
+	SetHTTPStatus(httpStatus int)
 	LogString() string
+	MarshalJSON() ([]byte, error)
+	Error() string
+	Unwrap() error
+	Std() error
 }
 
 type Info map[string]interface{}
@@ -27,6 +36,8 @@ var (
 	DefaultOpts        = Opts{
 		OmitStack: false,
 	}
+	// JSONDebug makes MarshalJSON include the standard error and stack
+	JSONDebug = false
 )
 
 func Wrap(stdErr error, userMessage ...interface{}) Err {
@@ -63,7 +74,7 @@ func newErr(stdErr error, internalInfo Info, opts Opts, userMessageParts []inter
 	if internalInfo == nil {
 		internalInfo = Info{}
 	}
-	return &err{stack, time.Now(), stdErr, internalInfo, userMessage}
+	return &err{stack, time.Now(), stdErr, internalInfo, userMessage, 0}
 }
 
 type err struct {
@@ -72,6 +83,7 @@ type err struct {
 	stdErr       error
 	internalInfo Info
 	userMessage  string
+	httpStatus   int
 }
 
 func (e *err) Stack() []byte             { return e.stack }
@@ -80,6 +92,44 @@ func (e *err) StandardError() error      { return e.stdErr }
 func (e *err) UserMessage() string       { return e.userMessage }
 func (e *err) SetUserMessage(msg string) { e.userMessage = msg }
 func (e *err) InternalInfo() Info        { return e.internalInfo }
+func (e *err) HTTPStatus() int           { return e.httpStatus }
+func (e *err) SetHTTPStatus(status int)  { e.httpStatus = status }
+func (e *err) Unwrap() error             { return e.stdErr }
+
+// Std returns the err as a plain error, e.g to return at API boundaries. The
+// rich Err can be recovered with errors.As. A nil *err gives a nil error.
+func (e *err) Std() error {
+	if e == nil {
+		return nil
+	}
+	return e
+}
+
+// AllInfo flattens the internal info of this and all wrapped errs into one map.
+// Outer keys take precedence over inner keys.
+func (e *err) AllInfo() Info {
+	allInfo := Info{}
+	var chainErr error = e
+	for chainErr != nil {
+		if errsErr, ok := chainErr.(Err); ok {
+			for key, val := range errsErr.InternalInfo() {
+				if _, exists := allInfo[key]; !exists {
+					allInfo[key] = val
+				}
+			}
+		}
+		chainErr = errors.Unwrap(chainErr)
+	}
+	return allInfo
+}
+
+func (e *err) Error() string {
+	if e.stdErr != nil {
+		return e.stdErr.Error()
+	}
+	return e.userMessage
+}
+
 func (e *err) StandardErrorMessage() string {
 	if e == nil {
 		return ""
@@ -90,7 +140,34 @@ func (e *err) StandardErrorMessage() string {
 	return e.stdErr.Error()
 }
 func (e *err) LogString() string {
-	return fmt.Sprint("Error | UserMessage: ", e.userMessage, " | StandardError: "+e.StandardErrorMessage()+" | Stack: ", string(e.stack), " | tInternalInfo:[", e.internalInfo, "Time:", e.time)
+	return fmt.Sprint("Error | UserMessage: ", e.userMessage, " | StandardError: "+e.StandardErrorMessage()+" | Stack: ", string(e.stack), " | tInternalInfo:[", e.AllInfo(), "Time:", e.time)
 }
 
 func (e *err) String() string { return e.LogString() }
+
+type jsonErr struct {
+	Message       string `json:"message"`
+	Info          Info   `json:"info,omitempty"`
+	HTTPStatus    int    `json:"httpStatus,omitempty"`
+	StandardError string `json:"standardError,omitempty"`
+	Stack         string `json:"stack,omitempty"`
+}
+
+func (e *err) MarshalJSON() ([]byte, error) {
+	allInfo := e.AllInfo()
+	payload := jsonErr{Message: e.userMessage, Info: allInfo, HTTPStatus: e.httpStatus}
+	if JSONDebug {
+		payload.StandardError = e.StandardErrorMessage()
+		payload.Stack = string(e.stack)
+	}
+	bytes, stdErr := json.Marshal(payload)
+	if stdErr == nil {
+		return bytes, nil
+	}
+	// Some info values can't be marshaled - fall back to their string representations
+	payload.Info = Info{}
+	for key, val := range allInfo {
+		payload.Info[key] = fmt.Sprint(val)
+	}
+	return json.Marshal(payload)
+}
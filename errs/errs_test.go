@@ -0,0 +1,79 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readConfig(fail bool) error {
+	if fail {
+		return Wrap(io.EOF, "Could not read config").Std()
+	}
+	return nil
+}
+
+func TestStd(t *testing.T) {
+	assert(t, readConfig(false) == nil)
+	stdErr := readConfig(true)
+	assert(t, stdErr != nil && stdErr.Error() == io.EOF.Error())
+	assert(t, errors.Is(stdErr, io.EOF))
+	var errsErr Err
+	assert(t, errors.As(stdErr, &errsErr))
+	assert(t, errsErr.UserMessage() == "Could not read config")
+
+	var nilErr *err
+	assert(t, nilErr.Std() == nil)
+}
+
+func TestAllInfo(t *testing.T) {
+	inner := WrapWithInfo(io.EOF, Info{"Description": "Read error", "Path": "config.json"})
+	outer := WrapWithInfo(inner, Info{"Description": "Load error", "Service": "api"})
+	allInfo := outer.AllInfo()
+	assert(t, len(allInfo) == 3 && allInfo["Description"] == "Load error")
+	assert(t, allInfo["Path"] == "config.json" && allInfo["Service"] == "api")
+	assert(t, len(outer.InternalInfo()) == 2)
+	assert(t, len(New(nil).AllInfo()) == 0)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	err := WrapWithInfo(io.EOF, Info{"Field": "Email"})
+	err.SetHTTPStatus(500)
+	jsonBytes, stdErr := json.Marshal(err)
+	assert(t, stdErr == nil)
+	var payload map[string]interface{}
+	assert(t, json.Unmarshal(jsonBytes, &payload) == nil)
+	assert(t, payload["message"] == DefaultUserMessage && payload["httpStatus"] == 500.0)
+	assert(t, payload["info"].(map[string]interface{})["Field"] == "Email")
+	_, hasStack := payload["stack"]
+	_, hasStandardError := payload["standardError"]
+	assert(t, !hasStack && !hasStandardError)
+
+	JSONDebug = true
+	defer func() { JSONDebug = false }()
+	jsonBytes, _ = json.Marshal(err)
+	payload = nil
+	assert(t, json.Unmarshal(jsonBytes, &payload) == nil)
+	assert(t, payload["standardError"] == "EOF" && strings.Contains(payload["stack"].(string), "TestMarshalJSON"))
+}
+
+func TestMarshalJSONUnmarshalableInfo(t *testing.T) {
+	err := New(Info{"Callback": func() {}, "Id": 7}, "Could not save")
+	jsonBytes, stdErr := json.Marshal(err)
+	assert(t, stdErr == nil)
+	var payload struct {
+		Message string
+		Info    map[string]string
+	}
+	assert(t, json.Unmarshal(jsonBytes, &payload) == nil)
+	assert(t, payload.Message == "Could not save" && payload.Info["Id"] == "7" && strings.HasPrefix(payload.Info["Callback"], "0x"))
+}
+
+func assert(t *testing.T, shouldBeTrue bool) {
+	if shouldBeTrue {
+		return
+	}
+	t.Error("assert failed")
+}
@@ -16,7 +16,7 @@ func Uid(numChars int) (uid string, err errs.Err) {
 	buf := make([]byte, numChars)
 	_, stdErr := io.ReadFull(rand.Reader, buf)
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, nil)
+		err = errs.WrapWithInfo(stdErr, nil)
 		return
 	}
 
@@ -0,0 +1,63 @@
+package sql
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ActiveQuery is a query which holds a connection, as listed by Shard.ActiveQueries.
+type ActiveQuery struct {
+	Query    string
+	Started  time.Time
+	Duration time.Duration
+}
+
+// activeQueries is shared between a shard and its transaction and session shards
+type activeQueries struct {
+	mutex   sync.Mutex
+	nextId  int64
+	queries map[int64]ActiveQuery
+}
+
+// SetTrackActiveQueries makes the shard track which queries hold connections, for
+// ActiveQueries. A query is tracked until its connection is returned to the pool. With an
+// acquire timeout, that includes reading the rows of Query; otherwise only running it.
+func (s *Shard) SetTrackActiveQueries(track bool) {
+	s.trackActive = track
+}
+
+// ActiveQueries lists the tracked queries which currently hold connections, longest
+// running first, e.g to diagnose which queries exhaust the connection pool.
+func (s *Shard) ActiveQueries() []ActiveQuery {
+	if s.active == nil {
+		return nil
+	}
+	s.active.mutex.Lock()
+	defer s.active.mutex.Unlock()
+	now := time.Now()
+	queries := make([]ActiveQuery, 0, len(s.active.queries))
+	for _, activeQuery := range s.active.queries {
+		activeQuery.Duration = now.Sub(activeQuery.Started)
+		queries = append(queries, activeQuery)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Started.Before(queries[j].Started) })
+	return queries
+}
+
+// trackQuery tracks query as active, if tracking is enabled, until untrack is called
+func (s *Shard) trackQuery(query string) (untrack func()) {
+	if !s.trackActive || s.active == nil {
+		return func() {}
+	}
+	s.active.mutex.Lock()
+	defer s.active.mutex.Unlock()
+	s.active.nextId += 1
+	id := s.active.nextId
+	s.active.queries[id] = ActiveQuery{Query: query, Started: time.Now()}
+	return func() {
+		s.active.mutex.Lock()
+		defer s.active.mutex.Unlock()
+		delete(s.active.queries, id)
+	}
+}
@@ -0,0 +1,109 @@
+package sql
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// Lookup is a read-only cache of a small reference table, e.g statuses or categories,
+// keyed by the rows' Id field. Create one with Shard.LoadLookup.
+type Lookup struct {
+	shard          *Shard
+	mapType        reflect.Type
+	query          string
+	args           []interface{}
+	ttl            time.Duration
+	onRefreshError func(err errs.Err)
+	mutex          sync.RWMutex
+	items          reflect.Value
+	refreshedAt    time.Time // The last refresh attempt, successful or not
+	refreshing     bool
+}
+
+// LoadLookup selects rows into output, a pointer to a map keyed by the rows' Id field,
+// and returns a Lookup which can refresh it:
+//
+//	var statuses map[int64]*Status
+//	lookup, err := shard.LoadLookup(&statuses, "SELECT Id, Name FROM Status")
+//	lookup.SetTTL(time.Minute)
+//	status, found := lookup.Get(int64(1))
+func (s *Shard) LoadLookup(output interface{}, query string, args ...interface{}) (*Lookup, errs.Err) {
+	err := s.SelectMapBy(output, "Id", query, args...)
+	if err != nil {
+		return nil, err
+	}
+	items := reflect.ValueOf(output).Elem()
+	return &Lookup{shard: s, mapType: items.Type(), query: query, args: args, items: items, refreshedAt: time.Now()}, nil
+}
+
+// SetTTL makes Get refresh the lookup when it is older than ttl. Zero disables auto-refresh.
+func (l *Lookup) SetTTL(ttl time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.ttl = ttl
+}
+
+// SetRefreshErrorHandler sets a function which is called with the errors of
+// refreshes made by Get, e.g to log them.
+func (l *Lookup) SetRefreshErrorHandler(onRefreshError func(err errs.Err)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.onRefreshError = onRefreshError
+}
+
+// Refresh reloads the lookup. On error the previously loaded items are kept.
+func (l *Lookup) Refresh() errs.Err {
+	items := reflect.New(l.mapType)
+	err := l.shard.SelectMapBy(items.Interface(), "Id", l.query, l.args...)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refreshedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	l.items = items.Elem()
+	return nil
+}
+
+// Get returns the item with the given id. If the lookup is older than its TTL it
+// is refreshed first, but failed refreshes fall back to the previously loaded items
+// and are not retried until the TTL has passed again. While one caller refreshes,
+// concurrent callers get the previously loaded items.
+func (l *Lookup) Get(id interface{}) (item interface{}, found bool) {
+	l.mutex.Lock()
+	refresh := l.ttl > 0 && !l.refreshing && time.Since(l.refreshedAt) > l.ttl
+	l.refreshing = l.refreshing || refresh
+	onRefreshError := l.onRefreshError
+	l.mutex.Unlock()
+	if refresh {
+		err := l.Refresh()
+		l.mutex.Lock()
+		l.refreshing = false
+		l.mutex.Unlock()
+		if err != nil && onRefreshError != nil {
+			onRefreshError(err)
+		}
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	idVal := reflect.ValueOf(id)
+	if !idVal.IsValid() || !idVal.Type().AssignableTo(l.mapType.Key()) {
+		return nil, false
+	}
+	itemVal := l.items.MapIndex(idVal)
+	if !itemVal.IsValid() {
+		return nil, false
+	}
+	return itemVal.Interface(), true
+}
+
+// Items returns the currently loaded map of items. It must not be modified.
+func (l *Lookup) Items() interface{} {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.items.Interface()
+}
@@ -0,0 +1,56 @@
+package sql
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+func TestLookup(t *testing.T) {
+	fake := &fakeDB{columns: []string{"Id", "Name"}, rows: [][]driver.Value{{"1", "Active"}}}
+	var statuses map[int64]*person
+	lookup, err := newFakeShard("TestLookup", fake).LoadLookup(&statuses, "SELECT Id, Name FROM Status")
+	assert(t, err == nil && len(statuses) == 1)
+	item, found := lookup.Get(int64(1))
+	assert(t, found && item.(*person).Name == "Active")
+	_, found = lookup.Get(1)
+	assert(t, !found) // Not the map's int64 key type
+	_, found = lookup.Get(nil)
+	assert(t, !found)
+
+	// Without a TTL, changes are only seen after Refresh
+	fake.rows = [][]driver.Value{{"1", "Enabled"}, {"2", "Disabled"}}
+	_, found = lookup.Get(int64(2))
+	assert(t, !found)
+	lookup.SetTTL(10 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	item, found = lookup.Get(int64(2))
+	assert(t, found && item.(*person).Name == "Disabled")
+}
+
+func TestLookupRefreshError(t *testing.T) {
+	fake := &fakeDB{columns: []string{"Id", "Name"}, rows: [][]driver.Value{{"1", "Active"}}}
+	var statuses map[int64]*person
+	lookup, err := newFakeShard("TestLookupRefreshError", fake).LoadLookup(&statuses, "SELECT Id, Name FROM Status")
+	assert(t, err == nil)
+	var refreshErrors []errs.Err
+	lookup.SetRefreshErrorHandler(func(err errs.Err) { refreshErrors = append(refreshErrors, err) })
+	lookup.SetTTL(10 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	fake.errOnRow = 1
+	item, found := lookup.Get(int64(1))
+	assert(t, found && item.(*person).Name == "Active")
+	assert(t, len(refreshErrors) == 1)
+	// The failed refresh is not retried until the TTL has passed again
+	_, found = lookup.Get(int64(1))
+	assert(t, found && len(refreshErrors) == 1)
+	assert(t, lookup.Refresh() != nil)
+
+	fake.errOnRow = 0
+	time.Sleep(20 * time.Millisecond)
+	_, found = lookup.Get(int64(1))
+	assert(t, found && len(refreshErrors) == 1)
+}
@@ -0,0 +1,22 @@
+package sql
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Metrics receives query and connection pool metrics from shards, e.g to expose
+// them with a Prometheus client. It is defined here so that this package does not
+// depend on any particular metrics library.
+type Metrics interface {
+	// ObserveQuery is called after every query with its duration and error, if any
+	ObserveQuery(dbName string, duration time.Duration, err error)
+	// SetPoolStats is called after every query with the current connection pool stats.
+	// Saturation is stats.InUse / stats.MaxOpenConnections.
+	SetPoolStats(dbName string, stats sql.DBStats)
+}
+
+// SetMetrics sets the metrics which the shard reports to after every query.
+func (s *Shard) SetMetrics(metrics Metrics) {
+	s.metrics = metrics
+}
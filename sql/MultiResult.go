@@ -0,0 +1,56 @@
+package sql
+
+import "github.com/marcuswestin/fun-go/errs"
+
+// MultiResult iterates over the result sets of a query which returns several,
+// e.g a CALL of a stored procedure. Close it when done.
+type MultiResult struct {
+	shard *Shard
+	rows  *Rows
+	query string
+	args  []interface{}
+}
+
+// CallProc executes a query which may return multiple result sets:
+//
+//	res, err := shard.CallProc("CALL GetPersonAndFriends(?)", personId)
+//	defer res.Close()
+//	err = res.Select(&people)
+//	if res.NextResultSet() {
+//		err = res.Select(&friends)
+//	}
+func (s *Shard) CallProc(query string, args ...interface{}) (*MultiResult, errs.Err) {
+	rows, err := s.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiResult{s, rows, query, args}, nil
+}
+
+// Select scans all rows of the current result set into output, like Shard.Select.
+func (m *MultiResult) Select(output interface{}) errs.Err {
+	outputReflection, err := m.shard.selectOutput(output, m.query, m.args)
+	if err != nil {
+		return err
+	}
+	return m.shard.scanRows(outputReflection, m.rows.Rows, m.query, m.args)
+}
+
+// NextResultSet moves on to the next result set, and reports whether there is one.
+func (m *MultiResult) NextResultSet() bool {
+	return m.rows.NextResultSet()
+}
+
+func (m *MultiResult) Err() errs.Err {
+	if stdErr := m.rows.Err(); stdErr != nil {
+		return errs.WrapWithInfo(stdErr, m.shard.errInfo("MultiResult rows.Err() error", m.query, m.args))
+	}
+	return nil
+}
+
+func (m *MultiResult) Close() errs.Err {
+	if stdErr := m.rows.Close(); stdErr != nil {
+		return errs.WrapWithInfo(stdErr, m.shard.errInfo("MultiResult rows.Close() error", m.query, m.args))
+	}
+	return nil
+}
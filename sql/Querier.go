@@ -0,0 +1,30 @@
+package sql
+
+import (
+	"database/sql"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// Querier is implemented by *Shard, both for regular and transaction shards.
+// Depend on it rather than on *Shard to be able to inject fakes in tests.
+type Querier interface {
+	Query(query string, args ...interface{}) (*Rows, errs.Err)
+	Exec(query string, args ...interface{}) (sql.Result, errs.Err)
+	Select(output interface{}, query string, args ...interface{}) errs.Err
+	SelectOne(output interface{}, query string, args ...interface{}) errs.Err
+	SelectMaybe(output interface{}, query string, args ...interface{}) (found bool, err errs.Err)
+	Insert(query string, args ...interface{}) (id int64, err errs.Err)
+	Update(query string, args ...interface{}) (rowsAffected int64, err errs.Err)
+	UpdateOne(query string, args ...interface{}) errs.Err
+	UpdateNum(num int64, query string, args ...interface{}) errs.Err
+	DeleteOne(query string, args ...interface{}) errs.Err
+	SelectInt(query string, args ...interface{}) (int64, errs.Err)
+	SelectString(query string, args ...interface{}) (string, errs.Err)
+	SelectUint(query string, args ...interface{}) (uint, errs.Err)
+	SelectIntMaybe(query string, args ...interface{}) (num int64, found bool, err errs.Err)
+	SelectStringMaybe(query string, args ...interface{}) (str string, found bool, err errs.Err)
+	SelectUintMaybe(query string, args ...interface{}) (num uint, found bool, err errs.Err)
+}
+
+var _ Querier = (*Shard)(nil)
@@ -0,0 +1,70 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// QueryBuilder assembles a SELECT query and its args, to pass on to e.g Shard.Select:
+//
+//	query, args := sql.Select("Id", "Name").From("Person").Where("Age > ?", 30).OrderBy("Name").Limit(10).Build()
+//	err := shard.Select(&people, query, args...)
+//
+// It does no escaping or validation beyond keeping track of the args of each condition.
+type QueryBuilder struct {
+	columns    []string
+	table      string
+	conditions []string
+	args       []interface{}
+	orderBy    []string
+	limit      int
+}
+
+// Select starts building a query which selects the given columns, or * if none are given.
+func Select(columns ...string) *QueryBuilder {
+	return &QueryBuilder{columns: columns}
+}
+
+// From sets the table to select from.
+func (b *QueryBuilder) From(table string) *QueryBuilder {
+	b.table = table
+	return b
+}
+
+// Where adds a condition with its placeholder args. Multiple conditions are ANDed.
+func (b *QueryBuilder) Where(condition string, args ...interface{}) *QueryBuilder {
+	b.conditions = append(b.conditions, condition)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// OrderBy adds columns to order by, e.g OrderBy("Name", "Id DESC").
+func (b *QueryBuilder) OrderBy(columns ...string) *QueryBuilder {
+	b.orderBy = append(b.orderBy, columns...)
+	return b
+}
+
+// Limit sets the maximum number of rows to select. Zero means no limit.
+func (b *QueryBuilder) Limit(limit int) *QueryBuilder {
+	b.limit = limit
+	return b
+}
+
+// Build returns the assembled query and its args.
+func (b *QueryBuilder) Build() (query string, args []interface{}) {
+	columns := "*"
+	if len(b.columns) > 0 {
+		columns = strings.Join(b.columns, ", ")
+	}
+	query = "SELECT " + columns + " FROM " + b.table
+	if len(b.conditions) > 0 {
+		query += " WHERE (" + strings.Join(b.conditions, ") AND (") + ")"
+	}
+	if len(b.orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(b.orderBy, ", ")
+	}
+	if b.limit > 0 {
+		query += " LIMIT " + strconv.Itoa(b.limit)
+	}
+	return query, b.args
+}
@@ -0,0 +1,60 @@
+package sql
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"io"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// SelectCSV streams the rows of a query as CSV onto w, with the column names as
+// header. Rows are written one by one, so memory stays bounded for large exports.
+// NULL values are written as empty cells.
+func (s *Shard) SelectCSV(w io.Writer, query string, args ...interface{}) errs.Err {
+	rows, err := s.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, stdErr := rows.Columns()
+	if stdErr != nil {
+		return errs.WrapWithInfo(stdErr, s.errInfo("SelectCSV rows.Columns error", query, args))
+	}
+	csvWriter := csv.NewWriter(w)
+	stdErr = csvWriter.Write(columns)
+	if stdErr != nil {
+		return errs.WrapWithInfo(stdErr, s.errInfo("SelectCSV header write error", query, args))
+	}
+
+	vals := make([]interface{}, len(columns))
+	for i := range vals {
+		vals[i] = &sql.RawBytes{}
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		stdErr = rows.Scan(vals...)
+		if stdErr != nil {
+			return errs.WrapWithInfo(stdErr, s.errInfo("SelectCSV rows.Scan error", query, args))
+		}
+		for i, val := range vals {
+			record[i] = string(*val.(*sql.RawBytes))
+		}
+		stdErr = csvWriter.Write(record)
+		if stdErr != nil {
+			return errs.WrapWithInfo(stdErr, s.errInfo("SelectCSV row write error", query, args))
+		}
+	}
+	stdErr = rows.Err()
+	if stdErr != nil {
+		return errs.WrapWithInfo(stdErr, s.errInfo("SelectCSV rows.Err() error", query, args))
+	}
+
+	csvWriter.Flush()
+	stdErr = csvWriter.Error()
+	if stdErr != nil {
+		return errs.WrapWithInfo(stdErr, s.errInfo("SelectCSV flush error", query, args))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package sql
+
+import (
+	"reflect"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// SelectT is a typed companion to Shard.Select. It takes any Querier, e.g a shard or a transaction shard:
+//
+//	users, err := sql.SelectT[*User](shard, "SELECT Id, Name FROM User")
+//
+// The T suffix is because sql.Select starts a QueryBuilder.
+func SelectT[T any](q Querier, query string, args ...interface{}) (items []T, err errs.Err) {
+	err = q.Select(&items, query, args...)
+	return
+}
+
+// SelectOneT is a typed companion to Shard.SelectMaybe. T may be a struct or a pointer to a struct.
+func SelectOneT[T any](q Querier, query string, args ...interface{}) (item T, found bool, err errs.Err) {
+	if typ := reflect.TypeOf(item); typ != nil && typ.Kind() == reflect.Ptr {
+		found, err = q.SelectMaybe(&item, query, args...)
+		return
+	}
+	var itemPtr *T
+	found, err = q.SelectMaybe(&itemPtr, query, args...)
+	if found {
+		item = *itemPtr
+	}
+	return
+}
+
+// SelectScalarT is a typed companion to Shard.SelectScalar:
+//
+//	maxPrice, found, err := sql.SelectScalarT[float64](shard, "SELECT MAX(Price) FROM Product")
+func SelectScalarT[T any](s *Shard, query string, args ...interface{}) (val T, found bool, err errs.Err) {
+	found, err = s.SelectScalar(&val, query, args...)
+	return
+}
+
+// SelectEach scans rows one by one into the same reused struct, and calls fn with it,
+// e.g for result sets too large to select into a slice. It stops at the first error
+// returned by fn. The struct is overwritten by the next row, so fn must not keep it:
+//
+//	err := sql.SelectEach(shard, func(person *Person) errs.Err {
+//		return export(person.Id, person.Name)
+//	}, "SELECT Id, Name FROM Person")
+func SelectEach[T any](s *Shard, fn func(row *T) errs.Err, query string, args ...interface{}) errs.Err {
+	var row, zero T
+	rows, err := s.QueryStruct(&row, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row = zero
+		err = rows.scanInto(reflect.ValueOf(&row).Elem())
+		if err != nil {
+			return err
+		}
+		err = s.runCallback("Panic during SelectEach", func() errs.Err { return fn(&row) }, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
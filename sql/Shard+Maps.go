@@ -0,0 +1,74 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// SelectMaps selects all rows as maps of column names to values, e.g for ad-hoc queries,
+// admin tooling and debugging. Values of integer columns are int64, values of FLOAT and
+// DOUBLE columns are float64, NULL values are nil, and other values are strings unless
+// the driver returns them typed, e.g time.Time with MySQL's parseTime=true.
+func (s *Shard) SelectMaps(query string, args ...interface{}) (maps []map[string]interface{}, err errs.Err) {
+	rows, err := s.Query(query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	columnTypes, stdErr := rows.ColumnTypes()
+	if stdErr != nil {
+		err = errs.WrapWithInfo(stdErr, s.errInfo("SelectMaps rows.ColumnTypes error", query, args))
+		return
+	}
+	vals := make([]interface{}, len(columnTypes))
+	valPtrs := make([]interface{}, len(columnTypes))
+	for i := range vals {
+		valPtrs[i] = &vals[i]
+	}
+	maps = []map[string]interface{}{}
+	for rows.Next() {
+		stdErr = rows.Scan(valPtrs...)
+		if stdErr != nil {
+			err = errs.WrapWithInfo(stdErr, s.errInfo("SelectMaps rows.Scan error", query, args))
+			return
+		}
+		rowMap := make(map[string]interface{}, len(columnTypes))
+		for i, columnType := range columnTypes {
+			rowMap[columnType.Name()] = mapValue(columnType.DatabaseTypeName(), vals[i])
+		}
+		maps = append(maps, rowMap)
+	}
+	stdErr = rows.Err()
+	if stdErr != nil {
+		err = errs.WrapWithInfo(stdErr, s.errInfo("SelectMaps rows.Err() error", query, args))
+	}
+	return
+}
+
+// mapValue converts text values to the Go type of their database type, e.g BIGINT to int64
+func mapValue(databaseTypeName string, val interface{}) interface{} {
+	bytes, isBytes := val.([]byte)
+	if !isBytes {
+		return val
+	}
+	str := string(bytes)
+	typeName := strings.ToUpper(databaseTypeName)
+	switch {
+	case strings.HasSuffix(typeName, "INT") || typeName == "INTEGER":
+		if strings.HasPrefix(typeName, "UNSIGNED") {
+			if uintVal, stdErr := strconv.ParseUint(str, 10, 64); stdErr == nil {
+				return uintVal
+			}
+		} else if intVal, stdErr := strconv.ParseInt(str, 10, 64); stdErr == nil {
+			return intVal
+		}
+	case typeName == "FLOAT" || typeName == "DOUBLE" || typeName == "REAL":
+		if floatVal, stdErr := strconv.ParseFloat(str, 64); stdErr == nil {
+			return floatVal
+		}
+	}
+	return str
+}
@@ -0,0 +1,283 @@
+package sql
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// InsertStruct inserts obj, a struct or struct pointer, into table. The struct's
+// fields are mapped to columns like when scanning, and fields tagged `fun:"-"` are
+// skipped. A zero integer Id field is also skipped, to let the database generate it,
+// and if obj is a struct pointer the generated id is written back into the Id field.
+func (s *Shard) InsertStruct(table string, obj interface{}) (id int64, err errs.Err) {
+	structVal := reflect.Indirect(reflect.ValueOf(obj))
+	if structVal.Kind() != reflect.Struct {
+		err = errs.New(errs.Info{"Description": "InsertStruct expects a struct or struct pointer", "Table": table})
+		return
+	}
+	columns, args, idVal := insertColumns(structVal)
+	err = checkIdentifiers(append([]string{table}, columns...))
+	if err != nil {
+		return
+	}
+	query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + placeholders(len(columns)) + ")"
+	id, err = s.Insert(query, args...)
+	if err != nil || !idVal.IsValid() || !idVal.CanSet() {
+		return
+	}
+	if idVal.CanInt() {
+		idVal.SetInt(id)
+	} else {
+		idVal.SetUint(uint64(id))
+	}
+	return
+}
+
+// insertColumns returns the columns and args to insert a struct, and its auto increment id field if it has one
+func insertColumns(structVal reflect.Value) (columns []string, args []interface{}, idVal reflect.Value) {
+	for _, column := range structColumns(structVal.Type()) {
+		fieldVal := structVal.FieldByIndex(column.field.Index)
+		if isAutoIncrementId(column, fieldVal) {
+			idVal = fieldVal
+			continue
+		}
+		columns = append(columns, column.name)
+		args = append(args, fieldVal.Interface())
+	}
+	return
+}
+
+// DefaultInsertBatchSize is the maximum number of rows per INSERT statement of InsertBatch,
+// unless changed with SetInsertBatchSize.
+const DefaultInsertBatchSize = 500
+
+// SetInsertBatchSize sets the maximum number of rows per INSERT statement of InsertBatch.
+// Zero uses DefaultInsertBatchSize.
+func (s *Shard) SetInsertBatchSize(batchSize int) {
+	s.insertBatch = batchSize
+}
+
+// maxInsertBatchArgs keeps InsertBatch statements below MySQL's limit of 65535 placeholders
+const maxInsertBatchArgs = 65535
+
+// InsertBatch inserts rows, a slice of structs or struct pointers, into table with
+// multi-row INSERT statements of up to the shard's insert batch size rows each. Columns are mapped
+// like in InsertStruct, and generated ids are not written back. The statements are
+// not atomic together, so use Transact to insert all rows or none:
+//
+//	err := shard.InsertBatch("Person", []Person{{Name: "Al"}, {Name: "Bo"}})
+func (s *Shard) InsertBatch(table string, rows interface{}) errs.Err {
+	rowsVal := reflect.ValueOf(rows)
+	if rowsVal.Kind() != reflect.Slice || !isStructOrStructPtr(rowsVal.Type().Elem()) {
+		return errs.New(errs.Info{"Description": "InsertBatch expects a slice of structs or struct pointers", "Table": table})
+	}
+	if rowsVal.Len() == 0 {
+		return nil
+	}
+	var columns []string
+	args := make([]interface{}, 0, rowsVal.Len())
+	for i := 0; i < rowsVal.Len(); i++ {
+		rowColumns, rowArgs, _ := insertColumns(reflect.Indirect(rowsVal.Index(i)))
+		if i == 0 {
+			columns = rowColumns
+		} else if len(rowColumns) != len(columns) {
+			return errs.New(errs.Info{"Description": "InsertBatch expects all rows or none to have zero Ids", "Table": table})
+		}
+		args = append(args, rowArgs...)
+	}
+	if len(columns) == 0 {
+		return errs.New(errs.Info{"Description": "InsertBatch expects a struct with at least one column", "Table": table})
+	}
+	err := checkIdentifiers(append([]string{table}, columns...))
+	if err != nil {
+		return err
+	}
+	batchSize := s.insertBatch
+	if batchSize == 0 {
+		batchSize = DefaultInsertBatchSize
+	}
+	if batchSize*len(columns) > maxInsertBatchArgs {
+		batchSize = maxInsertBatchArgs / len(columns)
+	}
+	rowPlaceholders := "(" + placeholders(len(columns)) + ")"
+	for start := 0; start < rowsVal.Len(); start += batchSize {
+		numRows := batchSize
+		if start+numRows > rowsVal.Len() {
+			numRows = rowsVal.Len() - start
+		}
+		query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES " +
+			strings.TrimSuffix(strings.Repeat(rowPlaceholders+", ", numRows), ", ")
+		_, err = s.Exec(query, args[start*len(columns):(start+numRows)*len(columns)]...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStructOrStructPtr returns true for struct types and pointers to struct types
+func isStructOrStructPtr(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct
+}
+
+// Upsert inserts obj like InsertStruct, or if a row with the same primary or unique key
+// exists, updates its other columns. For shards with DollarPlaceholders, i.e Postgres,
+// it uses ON CONFLICT on the primary key column (see UpdateStructByPK), and otherwise
+// MySQL's ON DUPLICATE KEY UPDATE.
+func (s *Shard) Upsert(table string, obj interface{}) errs.Err {
+	structVal := reflect.Indirect(reflect.ValueOf(obj))
+	if structVal.Kind() != reflect.Struct {
+		return errs.New(errs.Info{"Description": "Upsert expects a struct or struct pointer", "Table": table})
+	}
+	keyColumn := pkColumn(structVal.Type())
+	columns, args, _ := insertColumns(structVal)
+	if err := checkIdentifiers(append([]string{table}, columns...)); err != nil {
+		return err
+	}
+	var updates []string
+	for _, column := range columns {
+		if column == keyColumn {
+			continue
+		}
+		if s.placeholders == DollarPlaceholders {
+			updates = append(updates, column+" = EXCLUDED."+column)
+		} else {
+			updates = append(updates, column+" = VALUES("+column+")")
+		}
+	}
+	if len(updates) == 0 || (keyColumn == "" && s.placeholders == DollarPlaceholders) {
+		return errs.New(errs.Info{"Description": "Upsert expects a struct with a primary key and at least one other column", "Table": table})
+	}
+	query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + placeholders(len(columns)) + ")"
+	if s.placeholders == DollarPlaceholders {
+		query += " ON CONFLICT (" + keyColumn + ") DO UPDATE SET " + strings.Join(updates, ", ")
+	} else {
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	}
+	_, err := s.Exec(query, args...)
+	return err
+}
+
+// UpdateStruct updates the row of table whose key column matches obj's key field,
+// setting all other columns from obj's fields. Columns are mapped like in InsertStruct.
+// The key column defaults to obj's primary key column: the field tagged with the pk
+// option, e.g `fun:"user_id,pk"`, or otherwise the Id field. It errors unless exactly
+// one row was updated.
+//
+//	err := shard.UpdateStruct("Person", &person)
+//	err = shard.UpdateStruct("Person", &person, "email")
+func (s *Shard) UpdateStruct(table string, obj interface{}, keyColumn ...string) errs.Err {
+	switch len(keyColumn) {
+	case 0:
+		return s.updateStruct(table, obj, "", nil)
+	case 1:
+		return s.updateStruct(table, obj, keyColumn[0], nil)
+	default:
+		return errs.New(errs.Info{"Description": "UpdateStruct expects at most one key column", "Table": table, "KeyColumns": keyColumn})
+	}
+}
+
+// UpdateStructByPK is UpdateStruct keyed by obj's primary key column.
+func (s *Shard) UpdateStructByPK(table string, obj interface{}) errs.Err {
+	return s.updateStruct(table, obj, "", nil)
+}
+
+// UpdateStructFields is UpdateStruct which only sets the given columns. An empty keyColumn
+// defaults to obj's primary key column, e.g
+//
+//	err := shard.UpdateStructFields("Person", &person, "", "name", "email")
+func (s *Shard) UpdateStructFields(table string, obj interface{}, keyColumn string, columns ...string) errs.Err {
+	columnMask := map[string]bool{}
+	for _, column := range columns {
+		columnMask[column] = true
+	}
+	return s.updateStruct(table, obj, keyColumn, columnMask)
+}
+
+// updateStruct updates all non-key columns, or only those in columnMask if it is not nil.
+// An empty keyColumn defaults to the struct's primary key column.
+func (s *Shard) updateStruct(table string, obj interface{}, keyColumn string, columnMask map[string]bool) errs.Err {
+	structVal := reflect.Indirect(reflect.ValueOf(obj))
+	if structVal.Kind() != reflect.Struct {
+		return errs.New(errs.Info{"Description": "UpdateStruct expects a struct or struct pointer", "Table": table})
+	}
+	if keyColumn == "" {
+		keyColumn = pkColumn(structVal.Type())
+		if keyColumn == "" {
+			return errs.New(errs.Info{"Description": "UpdateStruct expects a key column or a struct with a pk tagged or Id field", "Table": table})
+		}
+	}
+	var columns []string
+	var args []interface{}
+	var keyArg interface{}
+	foundKey := false
+	for _, column := range structColumns(structVal.Type()) {
+		fieldVal := structVal.FieldByIndex(column.field.Index).Interface()
+		if column.name == keyColumn {
+			keyArg = fieldVal
+			foundKey = true
+		} else if columnMask == nil || columnMask[column.name] {
+			columns = append(columns, column.name)
+			args = append(args, fieldVal)
+		}
+	}
+	if !foundKey || len(columns) == 0 {
+		return errs.New(errs.Info{"Description": "UpdateStruct expects a struct with the key column and at least one other column",
+			"Table": table, "KeyColumn": keyColumn})
+	}
+	if err := checkIdentifiers(append([]string{table, keyColumn}, columns...)); err != nil {
+		return err
+	}
+	query := "UPDATE " + table + " SET " + strings.Join(columns, " = ?, ") + " = ? WHERE " + keyColumn + " = ?"
+	return s.UpdateOne(query, append(args, keyArg)...)
+}
+
+// pkColumn returns the column of the field tagged with the pk option, or otherwise of the Id field
+func pkColumn(structType reflect.Type) (keyColumn string) {
+	for _, column := range structColumns(structType) {
+		if column.opts["pk"] {
+			return column.name
+		} else if keyColumn == "" && isIdColumn(column) {
+			keyColumn = column.name
+		}
+	}
+	return
+}
+
+// isIdColumn returns true for fields named Id or ID, or tagged as the id column
+func isIdColumn(column structColumn) bool {
+	return column.field.Name == "Id" || column.field.Name == "ID" || strings.EqualFold(column.name, "id")
+}
+
+// isAutoIncrementId returns true for zero integer id columns
+func isAutoIncrementId(column structColumn, fieldVal reflect.Value) bool {
+	if !isIdColumn(column) {
+		return false
+	}
+	switch fieldVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fieldVal.IsZero()
+	}
+	return false
+}
+
+// placeholders returns num comma separated placeholders, e.g "?, ?, ?"
+func placeholders(num int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", num), ", ")
+}
+
+// checkIdentifiers checks table and column names, which can't be passed as query args
+func checkIdentifiers(names []string) errs.Err {
+	for _, name := range names {
+		if !identifierRegexp.MatchString(name) {
+			return errs.New(errs.Info{"Description": "Bad table or column name", "Name": name})
+		}
+	}
+	return nil
+}
@@ -1,11 +1,23 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/marcuswestin/fun-go/errs"
 )
@@ -13,63 +25,589 @@ import (
 type TxFunc func(shard *Shard) errs.Err
 
 type Shard struct {
-	DBName  string
-	db      *sql.DB // Nil for transaction and autocommit shard structs
-	sqlConn interface {
-		Exec(query string, args ...interface{}) (sql.Result, error)
-		Query(query string, args ...interface{}) (*sql.Rows, error)
+	DBName         string
+	db             *sql.DB // Nil for transaction, session and autocommit shard structs
+	sqlConn        sqlConn
+	isTx           bool
+	draining       int32
+	queryRewriter  func(query string) string
+	placeholders   PlaceholderStyle
+	metrics        Metrics
+	tracer         Tracer
+	watchdog       time.Duration
+	onWatchdog     func(query string, args []interface{})
+	skipFixArgs    bool
+	pinger         *periodicPing // Shared with transaction and session shards
+	columnMapper   func(column string) string
+	acquireTimeout time.Duration
+	slowQuery      time.Duration
+	onSlowQuery    SlowQueryFunc
+	maxColumnBytes int
+	truncateBytes  bool
+	recoverPanics  bool
+	strictNull     bool
+	autoIncrement  *int64 // Cached @@auto_increment_increment, shared with transaction shards
+	trackActive    bool
+	active         *activeQueries
+	errQueryLength int // Queries in error info are truncated to this length, unless zero
+	errOmitArgs    bool
+	maxPageSize    int
+	timeLocation   *time.Location
+	insertBatch    int
+}
+
+// NewShard returns a shard which runs queries on an already opened db, e.g a db
+// of another driver than the one set with SetOpener, or of a mock driver in tests.
+func NewShard(dbName string, db *sql.DB) *Shard {
+	return &Shard{DBName: dbName, db: db, sqlConn: db, autoIncrement: new(int64), pinger: &periodicPing{},
+		active: &activeQueries{queries: map[int64]ActiveQuery{}}}
+}
+
+// SlowQueryFunc is called with queries that take longer than the slow query threshold.
+// Caller is the file:line of the code outside of this package which issued the query.
+type SlowQueryFunc func(query string, args []interface{}, duration time.Duration, caller string)
+
+// sqlConn is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// connShard returns a copy of the shard, with the same settings, which runs all queries on conn.
+func (s *Shard) connShard(conn sqlConn, isTx bool) *Shard {
+	shard := *s
+	shard.db = nil
+	shard.sqlConn = conn
+	shard.isTx = isTx
+	return &shard
+}
+
+// SetAcquireTimeout makes queries, transactions and sessions fail with an error, rather than
+// block indefinitely, when no connection becomes available within the given duration.
+// Zero disables the timeout.
+func (s *Shard) SetAcquireTimeout(timeout time.Duration) {
+	s.acquireTimeout = timeout
+}
+
+// borrowConn returns the connection to run a query on. With an acquire timeout, a
+// dedicated connection is borrowed from the pool, and release must be called to return it.
+func (s *Shard) borrowConn(ctx context.Context, query string, args []interface{}) (conn sqlConn, release func(), err errs.Err) {
+	if atomic.LoadInt32(&s.draining) == 1 {
+		return nil, nil, errs.New(s.errInfo("Shard is draining", query, args))
+	}
+	if s.acquireTimeout == 0 || s.db == nil {
+		return s.sqlConn, s.trackQuery(query), nil
 	}
+	dbConn, err := s.acquireConn(ctx, s.errInfo("Could not get connection", query, args))
+	if err != nil {
+		return nil, nil, err
+	}
+	untrack := s.trackQuery(query)
+	return dbConn, func() { dbConn.Close(); untrack() }, nil
 }
 
-func (s *Shard) Transact(txFun TxFunc) errs.Err {
-	conn, stdErr := s.db.Begin()
+// acquireConn borrows a dedicated connection from the pool, which must be closed to return it.
+// With an acquire timeout, it fails if no connection becomes available in time.
+func (s *Shard) acquireConn(ctx context.Context, info errs.Info) (*sql.Conn, errs.Err) {
+	acquireCtx := ctx
+	if s.acquireTimeout != 0 {
+		var cancel context.CancelFunc
+		acquireCtx, cancel = context.WithTimeout(ctx, s.acquireTimeout)
+		defer cancel()
+	}
+	dbConn, stdErr := s.db.Conn(acquireCtx)
 	if stdErr != nil {
-		return errs.Wrap(stdErr, errs.Info{"Description": "Could not open transaction"})
+		// Only the acquire timeout itself means the pool is exhausted, not e.g a canceled ctx
+		if s.acquireTimeout != 0 && errors.Is(stdErr, context.DeadlineExceeded) && ctx.Err() == nil {
+			info["Description"] = fmt.Sprintf("pool exhausted: no connection available after %s (pool size %d)",
+				s.acquireTimeout, s.db.Stats().MaxOpenConnections)
+		}
+		return nil, errs.WrapWithInfo(stdErr, info)
 	}
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			rbErr := conn.Rollback()
-			panic(errs.New(errs.Info{
-				"Description": "Panic during sql transcation",
-				"PanicErr":    panicErr,
-				"RollbackErr": rbErr,
-			}))
+	return dbConn, nil
+}
+
+// SetSlowQueryThreshold makes the shard call onSlowQuery for every query which takes
+// longer than threshold. Zero threshold disables it.
+func (s *Shard) SetSlowQueryThreshold(threshold time.Duration, onSlowQuery SlowQueryFunc) {
+	s.slowQuery = threshold
+	s.onSlowQuery = onSlowQuery
+}
+
+// SetMaxColumnBytes limits how many bytes of each column value get scanned onto outputs.
+// Larger values are truncated if truncate is true, and otherwise make the scan fail.
+// Fields tagged `fun:",truncate"` are always truncated. Note that the driver has
+// already read the full value, but it is never copied onto the output. Zero disables it.
+func (s *Shard) SetMaxColumnBytes(maxBytes int, truncate bool) {
+	s.maxColumnBytes = maxBytes
+	s.truncateBytes = truncate
+}
+
+// limitColumnBytes truncates value to the max column bytes, or errors if it should not be truncated.
+func (s *Shard) limitColumnBytes(column string, value *sql.RawBytes, truncate bool, query string, args []interface{}) errs.Err {
+	if s.maxColumnBytes <= 0 || len(*value) <= s.maxColumnBytes {
+		return nil
+	}
+	if !truncate && !s.truncateBytes {
+		return errs.New(s.errInfo("Column value exceeds max column bytes", query, args, errs.Info{
+			"Column": column, "Bytes": len(*value), "MaxColumnBytes": s.maxColumnBytes}))
+	}
+	*value = (*value)[:s.maxColumnBytes]
+	return nil
+}
+
+// SetStrictNull makes scanning NULL into fields which can't hold NULL, e.g int64 rather
+// than *int64 or sql.NullInt64, fail rather than leave the field's zero value. Fields
+// tagged `fun:",notnull"` are always strict.
+func (s *Shard) SetStrictNull(strictNull bool) {
+	s.strictNull = strictNull
+}
+
+// checkNull errors if value is NULL, and it should be scanned strictly into a field which can't hold NULL
+func (s *Shard) checkNull(column string, reflectVal reflect.Value, value *sql.RawBytes, notNull bool, query string, args []interface{}) errs.Err {
+	if *value != nil || (!notNull && !s.strictNull) || isScannerType(reflectVal.Type()) {
+		return nil
+	}
+	switch reflectVal.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return nil
+	}
+	return errs.New(s.errInfo("NULL value for non-nullable field of column "+column, query, args, errs.Info{"FieldType": reflectVal.Type().String()}))
+}
+
+// SetQueryWatchdog makes the shard call onStuckQuery for every query which has not
+// completed within the given duration. The query is not cancelled. Zero disables it.
+func (s *Shard) SetQueryWatchdog(timeout time.Duration, onStuckQuery func(query string, args []interface{})) {
+	s.watchdog = timeout
+	s.onWatchdog = onStuckQuery
+}
+
+func (s *Shard) startWatchdog(query string, args []interface{}) (stop func()) {
+	if s.watchdog == 0 || s.onWatchdog == nil {
+		return func() {}
+	}
+	onWatchdog := s.onWatchdog
+	timer := time.AfterFunc(s.watchdog, func() { onWatchdog(query, args) })
+	return func() { timer.Stop() }
+}
+
+// observeQuery is called after every query, and reports metrics and slow queries
+func (s *Shard) observeQuery(query string, args []interface{}, start time.Time, stdErr error) {
+	duration := time.Since(start)
+	if s.metrics != nil {
+		s.metrics.ObserveQuery(s.DBName, duration, stdErr)
+		if s.db != nil {
+			s.metrics.SetPoolStats(s.DBName, s.db.Stats())
 		}
-	}()
+	}
+	if s.slowQuery != 0 && s.onSlowQuery != nil && duration >= s.slowQuery {
+		s.onSlowQuery(query, args, duration, externalCaller())
+	}
+}
+
+// externalCaller returns the file:line of the first caller outside of this package
+func externalCaller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePath+".") {
+			return fmt.Sprint(frame.File, ":", frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+var packagePath = reflect.TypeOf(Shard{}).PkgPath()
+
+// SetQueryRewriter sets a function which rewrites every query before it is
+// checked and executed, e.g to prefix table names with a tenant schema.
+func (s *Shard) SetQueryRewriter(rewriter func(query string) string) {
+	s.queryRewriter = rewriter
+}
+
+// SetPlaceholderStyle makes the shard translate the ? placeholders of all queries
+// to the given style, e.g DollarPlaceholders for Postgres.
+func (s *Shard) SetPlaceholderStyle(style PlaceholderStyle) {
+	s.placeholders = style
+}
+
+func (s *Shard) rewriteQuery(query string) string {
+	if s.queryRewriter == nil {
+		return query
+	}
+	return s.queryRewriter(query)
+}
+
+// SetFixArgs enables or disables fixing of query args. It is enabled by default. See fixArgs.
+func (s *Shard) SetFixArgs(enabled bool) {
+	s.skipFixArgs = !enabled
+}
+
+// SetColumnMapper sets the function used to map column names onto struct field
+// names when scanning rows. It defaults to CamelCaseColumn.
+func (s *Shard) SetColumnMapper(mapper func(column string) string) {
+	s.columnMapper = mapper
+}
 
-	err := txFun(&Shard{s.DBName, nil, conn})
+// Commonly used transaction options for TransactWithOptions
+var (
+	ReadCommitted  = &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+	RepeatableRead = &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+	Serializable   = &sql.TxOptions{Isolation: sql.LevelSerializable}
+	ReadOnly       = &sql.TxOptions{ReadOnly: true}
+)
+
+// Transact runs txFun with a shard which runs all queries in a transaction, with the
+// same query helpers as s. The transaction is committed if txFun returns nil, and is
+// otherwise rolled back and txFun's error returned:
+//
+//	err := shard.Transact(func(tx *sql.Shard) errs.Err {
+//		_, err := tx.Exec("UPDATE Account SET Balance=Balance-? WHERE Id=?", amount, fromId)
+//		...
+//	})
+func (s *Shard) Transact(txFun TxFunc) errs.Err {
+	return s.TransactWithOptions(nil, txFun)
+}
+
+// TransactWithOptions is like Transact, but begins the transaction with the given
+// isolation level and read-only flag. A nil opts uses the session defaults.
+// Note that MySQL only enforces read-only transactions for InnoDB tables.
+func (s *Shard) TransactWithOptions(opts *sql.TxOptions, txFun TxFunc) errs.Err {
+	if atomic.LoadInt32(&s.draining) == 1 {
+		return errs.New(errs.Info{"Description": "Shard is draining", "DBName": s.DBName})
+	}
+	conn, release, err := s.beginTx(opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+	err = s.runCallback("Panic during sql transaction", func() errs.Err { return txFun(s.connShard(conn, true)) },
+		func() { conn.Rollback() })
 	if err != nil {
 		rbErr := conn.Rollback()
 		if rbErr != nil {
-			return errs.Wrap(rbErr, errs.Info{"Description": "Transact rollback error", "TransactionError": err})
+			return errs.WrapWithInfo(rbErr, errs.Info{"Description": "Transact rollback error", "TransactionError": err})
 		}
-
+		return err
 	} else {
-		stdErr = conn.Commit()
+		stdErr := conn.Commit()
 		if stdErr != nil {
-			return errs.Wrap(stdErr, errs.Info{"Description": "Could not commit transaction"})
+			return errs.WrapWithInfo(stdErr, errs.Info{"Description": "Could not commit transaction"})
 		}
 	}
 
 	return nil
 }
 
+// beginTx begins a transaction on a dedicated connection, so that waiting for the connection
+// is bound by the acquire timeout but the transaction is not. Release must be called when
+// the transaction is done.
+func (s *Shard) beginTx(opts *sql.TxOptions) (tx *sql.Tx, release func(), err errs.Err) {
+	dbConn, err := s.acquireConn(context.Background(), errs.Info{"Description": "Could not open transaction", "DBName": s.DBName})
+	if err != nil {
+		return
+	}
+	tx, stdErr := dbConn.BeginTx(context.Background(), opts)
+	if stdErr != nil {
+		dbConn.Close()
+		err = errs.WrapWithInfo(stdErr, errs.Info{"Description": "Could not open transaction", "DBName": s.DBName})
+		return
+	}
+	return tx, func() { dbConn.Close() }, nil
+}
+
+// TransactRollback runs txFun in a transaction which is always rolled back, e.g to
+// run real SQL in integration tests without persisting anything. It returns txFun's error.
+func (s *Shard) TransactRollback(txFun TxFunc) (err errs.Err) {
+	if atomic.LoadInt32(&s.draining) == 1 {
+		return errs.New(errs.Info{"Description": "Shard is draining", "DBName": s.DBName})
+	}
+	conn, release, err := s.beginTx(nil)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer func() {
+		rbErr := conn.Rollback()
+		if rbErr != nil && err == nil {
+			err = errs.WrapWithInfo(rbErr, errs.Info{"Description": "TransactRollback rollback error"})
+		}
+	}()
+	return s.runCallback("Panic during sql TransactRollback", func() errs.Err { return txFun(s.connShard(conn, true)) }, nil)
+}
+
+// Session runs sessionFun with a shard which runs all queries on the same connection,
+// e.g for temporary tables, session variables and LAST_INSERT_ID(). Unlike Transact,
+// it does not begin or commit a transaction.
+func (s *Shard) Session(sessionFun TxFunc) errs.Err {
+	if atomic.LoadInt32(&s.draining) == 1 {
+		return errs.New(errs.Info{"Description": "Shard is draining", "DBName": s.DBName})
+	}
+	conn, err := s.acquireConn(context.Background(), errs.Info{"Description": "Could not open session connection", "DBName": s.DBName})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return s.runCallback("Panic during sql session", func() errs.Err { return sessionFun(s.connShard(conn, false)) }, nil)
+}
+
+// SetRecoverPanics makes Transact, TransactRollback, Session and SelectEach return
+// panics in their callbacks as errors. Otherwise panics are re-raised. Either way, the
+// transaction is rolled back and the connection is returned to the pool first.
+func (s *Shard) SetRecoverPanics(recoverPanics bool) {
+	s.recoverPanics = recoverPanics
+}
+
+// runCallback calls fun, and returns a panic in fun as an error if the shard recovers panics.
+// Otherwise onPanic, if any, is called while the panic continues, e.g to roll back a transaction.
+func (s *Shard) runCallback(description string, fun func() errs.Err, onPanic func()) (err errs.Err) {
+	panicking := true
+	defer func() {
+		if !panicking {
+			return
+		}
+		if s.recoverPanics {
+			err = errs.New(errs.Info{"Description": description, "PanicErr": recover(), "DBName": s.DBName})
+		} else if onPanic != nil {
+			onPanic()
+		}
+	}()
+	err = fun()
+	panicking = false
+	return
+}
+
+// Names which can't be passed as query args (tables, columns, savepoints) must match identifierRegexp
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Savepoint creates a named savepoint within a transaction shard.
+func (s *Shard) Savepoint(name string) errs.Err {
+	return s.execSavepoint("SAVEPOINT ", name)
+}
+
+// RollbackTo rolls the transaction back to the named savepoint.
+func (s *Shard) RollbackTo(name string) errs.Err {
+	return s.execSavepoint("ROLLBACK TO SAVEPOINT ", name)
+}
+
+// ReleaseSavepoint removes the named savepoint without rolling back.
+func (s *Shard) ReleaseSavepoint(name string) errs.Err {
+	return s.execSavepoint("RELEASE SAVEPOINT ", name)
+}
+
+func (s *Shard) execSavepoint(statement string, name string) errs.Err {
+	if !s.isTx {
+		return errs.New(errs.Info{"Description": "Savepoints are only supported within Transact", "Savepoint": name})
+	}
+	// Savepoint names cannot be passed as query args
+	if !identifierRegexp.MatchString(name) {
+		return errs.New(errs.Info{"Description": "Bad savepoint name", "Savepoint": name})
+	}
+	_, err := s.Exec(statement + name)
+	return err
+}
+
+// Drain stops the shard from starting new queries, waits for all borrowed connections
+// to be returned, and then closes the database. If ctx is done before all connections
+// have been returned, the database is closed anyway and ctx's error is returned.
+func (s *Shard) Drain(ctx context.Context) (err errs.Err) {
+	if s.db == nil {
+		return errs.New(errs.Info{"Description": "Drain is not supported on transaction and session shards", "DBName": s.DBName})
+	}
+	atomic.StoreInt32(&s.draining, 1)
+	s.stopPeriodicPing()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.db.Stats().InUse > 0 && err == nil {
+		select {
+		case <-ctx.Done():
+			err = errs.WrapWithInfo(ctx.Err(), errs.Info{"Description": "Drain timed out", "DBName": s.DBName, "InUse": s.db.Stats().InUse})
+		case <-ticker.C:
+		}
+	}
+	if stdErr := s.db.Close(); stdErr != nil && err == nil {
+		err = errs.WrapWithInfo(stdErr, errs.Info{"Description": "Drain close error", "DBName": s.DBName})
+	}
+	return
+}
+
+// PingErrorFunc is called with the error of every failed periodic ping of a shard.
+type PingErrorFunc func(shard *Shard, err errs.Err)
+
+// StartPeriodicPing starts a background goroutine which pings the database every interval,
+// and calls onPingError, unless nil, with the error of every failed ping. Failed pings are
+// retried with exponential backoff. It does not reconnect by itself: each ping checks a single
+// idle or new connection, and if the driver reports it as bad, database/sql discards it and
+// retries on a new connection. Close and Drain stop the pings.
+func (s *Shard) StartPeriodicPing(interval time.Duration, onPingError PingErrorFunc) errs.Err {
+	if s.db == nil {
+		return errs.New(errs.Info{"Description": "StartPeriodicPing is not supported on transaction and session shards", "DBName": s.DBName})
+	}
+	if interval <= 0 {
+		return errs.New(errs.Info{"Description": "StartPeriodicPing expects a positive interval", "DBName": s.DBName, "Interval": interval})
+	}
+	s.pinger.mutex.Lock()
+	defer s.pinger.mutex.Unlock()
+	if s.pinger.stop != nil {
+		return nil
+	}
+	s.pinger.stop = make(chan struct{})
+	go s.runPeriodicPing(interval, onPingError, s.pinger.stop)
+	return nil
+}
+
+type periodicPing struct {
+	mutex sync.Mutex
+	stop  chan struct{}
+}
+
+const minPingBackoff = 100 * time.Millisecond
+
+func (s *Shard) runPeriodicPing(interval time.Duration, onPingError PingErrorFunc, stop chan struct{}) {
+	wait := interval
+	backoff := minPingBackoff
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+		// PingContext only uses idle or new connections, never borrowed ones
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		stdErr := s.db.PingContext(ctx)
+		cancel()
+		if stdErr == nil {
+			wait = interval
+			backoff = minPingBackoff
+			continue
+		}
+		if onPingError != nil {
+			onPingError(s, errs.WrapWithInfo(stdErr, errs.Info{"Description": "Periodic ping error", "DBName": s.DBName}))
+		}
+		wait = backoff
+		if backoff *= 2; backoff > interval {
+			backoff = interval
+		}
+	}
+}
+
+// Close stops the shard's periodic ping, if any, and closes its database.
+func (s *Shard) Close() errs.Err {
+	if s.db == nil {
+		return errs.New(errs.Info{"Description": "Close is not supported on transaction and session shards", "DBName": s.DBName})
+	}
+	s.stopPeriodicPing()
+	if stdErr := s.db.Close(); stdErr != nil {
+		return errs.WrapWithInfo(stdErr, errs.Info{"Description": "Close error", "DBName": s.DBName})
+	}
+	return nil
+}
+
+func (s *Shard) stopPeriodicPing() {
+	s.pinger.mutex.Lock()
+	defer s.pinger.mutex.Unlock()
+	if s.pinger.stop != nil {
+		close(s.pinger.stop)
+		s.pinger.stop = nil
+	}
+}
+
+// Ping checks that the shard's database is reachable, e.g for health checks.
+func (s *Shard) Ping() errs.Err {
+	return s.PingContext(context.Background())
+}
+
+// PingContext is like Ping, but gives up when ctx is done.
+func (s *Shard) PingContext(ctx context.Context) errs.Err {
+	if s.db == nil {
+		return errs.New(errs.Info{"Description": "Ping is not supported on transaction and session shards", "DBName": s.DBName})
+	}
+	stdErr := s.db.PingContext(ctx)
+	if stdErr != nil {
+		return errs.WrapWithInfo(stdErr, errs.Info{"Description": "Ping error", "DBName": s.DBName})
+	}
+	return nil
+}
+
+// Rows are the rows of a query. Close releases their connection and stops tracking
+// the query, so it must always be called, e.g with defer rows.Close().
+type Rows struct {
+	*sql.Rows
+	release     func()
+	releaseOnce sync.Once
+}
+
+// Close closes the rows and releases their connection. It may be called more than once.
+func (r *Rows) Close() error {
+	stdErr := r.Rows.Close()
+	r.releaseOnce.Do(r.release)
+	return stdErr
+}
+
 // Query with fixed args
-func (s *Shard) Query(query string, args ...interface{}) (*sql.Rows, errs.Err) {
-	fixArgs(args)
-	rows, stdErr := s.sqlConn.Query(query, args...)
+func (s *Shard) Query(query string, args ...interface{}) (*Rows, errs.Err) {
+	return s.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is Query with a context, e.g to cancel the query or enforce a deadline.
+// The context also bounds how long to wait for a connection.
+func (s *Shard) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, errs.Err) {
+	query, err := s.prepareQuery(s.rewriteQuery(query), args)
+	if err != nil {
+		return nil, err
+	}
+	if !s.skipFixArgs {
+		fixArgs(args)
+	}
+	conn, release, err := s.borrowConn(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	stopWatchdog := s.startWatchdog(query, args)
+	ctx, finishSpan := s.startSpan(ctx, query)
+	rows, stdErr := conn.QueryContext(ctx, query, args...)
+	finishSpan(stdErr)
+	stopWatchdog()
+	s.observeQuery(query, args, start, stdErr)
 	if stdErr != nil {
-		return nil, errs.Wrap(stdErr, errInfo("Query sqlConn.Query() error", query, args))
+		release()
+		return nil, errs.WrapWithInfo(stdErr, s.errInfo("Query sqlConn.Query() error", query, args))
 	}
-	return rows, nil
+	return &Rows{Rows: rows, release: release}, nil
 }
 
 // Execute with fixed args
 func (s *Shard) Exec(query string, args ...interface{}) (sql.Result, errs.Err) {
-	fixArgs(args)
-	res, stdErr := s.sqlConn.Exec(query, args...)
+	return s.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec with a context. See QueryContext.
+func (s *Shard) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, errs.Err) {
+	query, err := s.prepareQuery(s.rewriteQuery(query), args)
+	if err != nil {
+		return nil, err
+	}
+	if !s.skipFixArgs {
+		fixArgs(args)
+	}
+	conn, release, err := s.borrowConn(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	start := time.Now()
+	stopWatchdog := s.startWatchdog(query, args)
+	ctx, finishSpan := s.startSpan(ctx, query)
+	res, stdErr := conn.ExecContext(ctx, query, args...)
+	finishSpan(stdErr)
+	stopWatchdog()
+	s.observeQuery(query, args, start, stdErr)
 	if stdErr != nil {
-		return nil, errs.Wrap(stdErr, errInfo("Exec sqlConn.Exec() error", query, args))
+		return nil, errs.WrapWithInfo(stdErr, s.errInfo("Exec sqlConn.Exec() error", query, args))
 	}
 	return res, nil
 }
@@ -90,7 +628,8 @@ func (s *Shard) ExecIgnoreDuplicateError(query string, args ...interface{}) (res
 
 /*
 Fix args by converting them to values of their underlying kind.
-This avoids problems in database/sql with e.g custom string types.
+This avoids problems in database/sql with custom string, int, uint, float, bool
+and time.Time types, e.g type UserID int64.
 Without fixArgs, the following code:
 
 	type Foo string
@@ -100,27 +639,66 @@ Without fixArgs, the following code:
 would give you the error:
 
 	sql: converting Exec argument #1's type: unsupported type Foo, a string
+
+Pointer args are dereferenced, and nil pointers become NULL, so that
+optional values can be passed as e.g *string, *int64 or *time.Time.
+
+Args which implement driver.Valuer are left untouched. Use Shard.SetFixArgs(false)
+to disable fixArgs altogether.
 */
 func fixArgs(args []interface{}) {
 	for i, arg := range args {
-		vArg := reflect.ValueOf(arg)
-		switch vArg.Kind() {
-		case reflect.String:
-			args[i] = vArg.String()
-			if args[i] == "" {
-				args[i] = nil
-			}
+		args[i] = fixArg(arg)
+	}
+}
+
+func fixArg(arg interface{}) interface{} {
+	if _, isValuer := arg.(driver.Valuer); isValuer {
+		return arg
+	}
+	vArg := reflect.ValueOf(arg)
+	switch vArg.Kind() {
+	case reflect.String:
+		if vArg.String() == "" {
+			return nil
 		}
+		return vArg.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return vArg.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return vArg.Uint()
+	case reflect.Float32, reflect.Float64:
+		return vArg.Float()
+	case reflect.Bool:
+		return vArg.Bool()
+	case reflect.Struct:
+		if vArg.Type() != timeType && vArg.Type().ConvertibleTo(timeType) {
+			return vArg.Convert(timeType).Interface() // E.g type Timestamp time.Time
+		}
+	case reflect.Ptr:
+		if vArg.IsNil() {
+			return nil
+		}
+		if vArg.Elem().Kind() == reflect.String {
+			return vArg.Elem().String() // Non-nil string pointers keep empty strings
+		}
+		return fixArg(vArg.Elem().Interface())
 	}
+	return arg
 }
 
+// ErrNotFound is wrapped by the errors of SelectOne, SelectInt, SelectString and SelectUint
+// when the query returns no rows, so that callers can check errors.Is(err, sql.ErrNotFound).
+// It is a plain error, so it carries no info which could be changed by its users.
+var ErrNotFound = errors.New("not found")
+
 func (s *Shard) SelectInt(query string, args ...interface{}) (num int64, err errs.Err) {
 	found, err := s.queryOne(query, args, &num)
 	if err != nil {
 		return
 	}
 	if !found {
-		err = errs.New(errInfo("Query returned no rows", query, args))
+		err = errs.WrapWithInfo(ErrNotFound, s.errInfo("Query returned no rows", query, args))
 		return
 	}
 	return
@@ -135,7 +713,7 @@ func (s *Shard) SelectString(query string, args ...interface{}) (str string, err
 	if found {
 		str = nullStr.String
 	} else {
-		err = errs.New(errInfo("Query returned no rows", query, args))
+		err = errs.WrapWithInfo(ErrNotFound, s.errInfo("Query returned no rows", query, args))
 		return
 	}
 	return
@@ -147,7 +725,7 @@ func (s *Shard) SelectUint(query string, args ...interface{}) (num uint, err err
 		return
 	}
 	if !found {
-		err = errs.New(errInfo("Query returned no rows", query, args))
+		err = errs.WrapWithInfo(ErrNotFound, s.errInfo("Query returned no rows", query, args))
 		return
 	}
 	return
@@ -201,6 +779,64 @@ func (s *Shard) SelectUintMaybe(query string, args ...interface{}) (num uint, fo
 	return
 }
 
+// Exists reports whether the query returns any rows, using SELECT EXISTS(query)
+// so that the database can stop at the first matching row. The result is scanned as a
+// bool, since MySQL returns 1 or 0 and Postgres returns a boolean.
+func (s *Shard) Exists(query string, args ...interface{}) (exists bool, err errs.Err) {
+	query = "SELECT EXISTS(" + strings.TrimRight(strings.TrimSpace(query), ";") + ")"
+	_, err = s.queryOne(query, args, &exists)
+	return
+}
+
+// SelectColumn selects all values of a single-column query, e.g "SELECT Name FROM Person"
+func (s *Shard) SelectColumn(query string, args ...interface{}) (strs []string, err errs.Err) {
+	err = s.Select(&strs, query, args...)
+	return
+}
+
+func (s *Shard) SelectIntColumn(query string, args ...interface{}) (nums []int64, err errs.Err) {
+	err = s.Select(&nums, query, args...)
+	return
+}
+
+func (s *Shard) SelectUintColumn(query string, args ...interface{}) (nums []uint, err errs.Err) {
+	err = s.Select(&nums, query, args...)
+	return
+}
+
+// SelectInts is the same as SelectIntColumn, e.g for "SELECT Id FROM Person WHERE ..."
+func (s *Shard) SelectInts(query string, args ...interface{}) (nums []int64, err errs.Err) {
+	return s.SelectIntColumn(query, args...)
+}
+
+// SelectStrings is the same as SelectColumn.
+func (s *Shard) SelectStrings(query string, args ...interface{}) (strs []string, err errs.Err) {
+	return s.SelectColumn(query, args...)
+}
+
+// SelectScalar selects a single value into out, which should be a pointer to any type which
+// can be scanned from a column, e.g *int64, *float64, *bool, *string or *time.Time:
+//
+//	var lastLogin time.Time
+//	found, err := shard.SelectScalar(&lastLogin, "SELECT LastLogin FROM Person WHERE Id=?", id)
+//
+// NULL values leave out untouched, or set it to nil if it is a pointer to a pointer.
+func (s *Shard) SelectScalar(out interface{}, query string, args ...interface{}) (found bool, err errs.Err) {
+	outVal := reflect.ValueOf(out)
+	if outVal.Kind() != reflect.Ptr || outVal.IsNil() {
+		err = errs.New(s.errInfo("SelectScalar expects a non-nil pointer", query, args))
+		return
+	}
+	var bytes []byte // Copied by rows.Scan, unlike sql.RawBytes which are only valid until rows.Next
+	found, err = s.queryOne(query, args, &bytes)
+	if err != nil || !found {
+		return
+	}
+	rawBytes := sql.RawBytes(bytes)
+	err = s.scanColumnValue("SelectScalar", outVal.Elem(), &rawBytes, query, args)
+	return
+}
+
 func (s *Shard) queryOne(query string, args []interface{}, out interface{}) (found bool, err errs.Err) {
 	rows, err := s.Query(query, args...)
 	if err != nil {
@@ -208,22 +844,32 @@ func (s *Shard) queryOne(query string, args []interface{}, out interface{}) (fou
 	}
 	defer rows.Close()
 
+	columns, stdErr := rows.Columns()
+	if stdErr != nil {
+		err = errs.WrapWithInfo(stdErr, s.errInfo("queryOne rows.Columns error", query, args))
+		return
+	}
+	if len(columns) != 1 {
+		err = errs.New(s.errInfo("Query returned more than one column", query, args, errs.Info{"Columns": columns}))
+		return
+	}
+
 	if rows.Next() {
-		stdErr := rows.Scan(out)
+		stdErr = rows.Scan(out)
 		if stdErr != nil {
-			err = errs.Wrap(stdErr, errInfo("queryOne rows.Scan error", query, args))
+			err = errs.WrapWithInfo(stdErr, s.errInfo("queryOne rows.Scan error", query, args))
 			return
 		}
 		if rows.Next() {
-			err = errs.New(errInfo("queryOne query returned too many rows", query, args))
+			err = errs.New(s.errInfo("Query returned more than one row", query, args))
 			return
 		}
 		found = true
 	}
 
-	stdErr := rows.Err()
+	stdErr = rows.Err()
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errInfo("queryOne rows.Err", query, args))
+		err = errs.WrapWithInfo(stdErr, s.errInfo("queryOne rows.Err", query, args))
 		return
 	}
 
@@ -240,7 +886,7 @@ func (s *Shard) UpdateNum(num int64, query string, args ...interface{}) (err err
 		return err
 	}
 	if rowsAffected != num {
-		return errs.New(errInfo("UpdateNum affected unexpected number of rows", query, args,
+		return errs.New(s.errInfo("UpdateNum affected unexpected number of rows", query, args,
 			errs.Info{"ExpectedRows": num, "AffectedRows": rowsAffected}))
 	}
 	return
@@ -254,17 +900,106 @@ func (s *Shard) Update(query string, args ...interface{}) (rowsAffected int64, e
 
 	rowsAffected, stdErr := res.RowsAffected()
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errInfo("Update RowsAffected error", query, args))
+		err = errs.WrapWithInfo(stdErr, s.errInfo("Update RowsAffected error", query, args))
 		return
 	}
 	return
 }
 
+// DeleteOne executes a delete and errors unless exactly one row was deleted.
+func (s *Shard) DeleteOne(query string, args ...interface{}) (err errs.Err) {
+	rowsAffected, err := s.Update(query, args...)
+	if err != nil {
+		return err
+	}
+	if rowsAffected != 1 {
+		return errs.New(s.errInfo("DeleteOne deleted unexpected number of rows", query, args,
+			errs.Info{"ExpectedRows": 1, "AffectedRows": rowsAffected}))
+	}
+	return
+}
+
+// UpdateBatch updates many rows in a single statement. The updates map is keyed by
+// the rows' keyColumn values, and each row update maps column names to new values:
+//
+//	shard.UpdateBatch("Person", "Id", map[interface{}]map[string]interface{}{
+//		1: {"Name": "Alice"},
+//		2: {"Name": "Bob", "Age": 30},
+//	})
+//
+// Columns missing from a row's update are left unchanged.
+func (s *Shard) UpdateBatch(table string, keyColumn string, updates map[interface{}]map[string]interface{}) (rowsAffected int64, err errs.Err) {
+	if len(updates) == 0 {
+		return
+	}
+	keys := make([]interface{}, 0, len(updates))
+	columnSet := map[string]bool{}
+	for key, update := range updates {
+		keys = append(keys, key)
+		for column := range update {
+			columnSet[column] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+	err = checkIdentifiers(append([]string{table, keyColumn}, columns...))
+	if err != nil {
+		return
+	}
+
+	var args []interface{}
+	sets := make([]string, len(columns))
+	for i, column := range columns {
+		cases := ""
+		for _, key := range keys {
+			if val, ok := updates[key][column]; ok {
+				cases += " WHEN ? THEN ?"
+				args = append(args, key, val)
+			}
+		}
+		sets[i] = column + " = CASE " + keyColumn + cases + " ELSE " + column + " END"
+	}
+	args = append(args, keys...)
+	query := "UPDATE " + table + " SET " + strings.Join(sets, ", ") +
+		" WHERE " + keyColumn + " IN (" + placeholders(len(keys)) + ")"
+	return s.Update(query, args...)
+}
+
+// lessKey orders UpdateBatch keys, so that the same updates always give the same
+// statement. Integers are ordered numerically, and other keys by their string form.
+func lessKey(a, b interface{}) bool {
+	aVal, bVal := reflect.ValueOf(a), reflect.ValueOf(b)
+	if isIntKind(aVal.Kind()) && isIntKind(bVal.Kind()) {
+		return aVal.Int() < bVal.Int()
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
 func (s *Shard) InsertIgnoreId(query string, args ...interface{}) (err errs.Err) {
 	_, err = s.Insert(query, args...)
 	return
 }
 
+// InsertNoId executes an insert without reading LastInsertId, e.g for tables
+// with UUID or composite primary keys where LastInsertId is meaningless.
+// With MySQL, select any generated keys in a follow-up query within Transact.
+func (s *Shard) InsertNoId(query string, args ...interface{}) (err errs.Err) {
+	_, err = s.Exec(query, args...)
+	return
+}
+
 func IsDuplicateEntryError(err errs.Err) bool {
 	str := err.StandardErrorMessage()
 	return strings.Contains(str, "Duplicate entry")
@@ -284,63 +1019,245 @@ func (s *Shard) Insert(query string, args ...interface{}) (id int64, err errs.Er
 	}
 	id, stdErr := res.LastInsertId()
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errInfo("Insert LastInsertIderror", query, args))
+		err = errs.WrapWithInfo(stdErr, s.errInfo("Insert LastInsertIderror", query, args))
+		return
+	}
+	return
+}
+
+// InsertReturning executes an insert and scans the returned id into output, like SelectScalar.
+// Use it with Postgres, where LastInsertId is not supported. " RETURNING id" is appended, after
+// trimming any trailing semicolon, unless the query already has a RETURNING clause outside of
+// quotes and comments, e.g for another key column:
+//
+//	var id int64
+//	err := shard.InsertReturning(&id, "INSERT INTO Person (Name) VALUES (?)", name)
+//	err = shard.InsertReturning(&uuid, "INSERT INTO Session (UserId) VALUES (?) RETURNING SessionUUID", userId)
+func (s *Shard) InsertReturning(output interface{}, query string, args ...interface{}) (err errs.Err) {
+	if !hasReturningClause(query, s.placeholders) {
+		query = strings.TrimRight(query, "; \t\r\n") + " RETURNING id"
+	}
+	found, err := s.SelectScalar(output, query, args...)
+	if err == nil && !found {
+		err = errs.New(s.errInfo("InsertReturning returned no row", query, args))
+	}
+	return
+}
+
+var returningRegexp = regexp.MustCompile(`(?i)^RETURNING\b`)
+
+// hasReturningClause reports whether the query has a RETURNING keyword outside of quotes and comments
+func hasReturningClause(query string, style PlaceholderStyle) bool {
+	return len(unquotedIndexes(query, style, func(i int) bool {
+		return (i == 0 || !isIdentifierByte(query[i-1])) && returningRegexp.MatchString(query[i:])
+	})) > 0
+}
+
+// isIdentifierByte returns true for bytes which can be part of an unquoted identifier
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// InsertBatchReturning executes a multi-row insert, e.g "INSERT INTO Person (Name) VALUES (?), (?)",
+// and returns the generated ids of all inserted rows. MySQL returns the id of the first row,
+// and the rest are computed with @@auto_increment_increment. This is only correct for a
+// single INSERT statement with contiguous ids, i.e without INSERT IGNORE, ON DUPLICATE KEY
+// UPDATE, triggers, or innodb_autoinc_lock_mode=2 (interleaved) with concurrent inserts.
+func (s *Shard) InsertBatchReturning(query string, args ...interface{}) (ids []int64, err errs.Err) {
+	step, err := s.autoIncrementStep()
+	if err != nil {
+		return
+	}
+	res, err := s.Exec(query, args...)
+	if err != nil {
+		return
+	}
+	firstId, stdErr := res.LastInsertId()
+	if stdErr != nil {
+		err = errs.WrapWithInfo(stdErr, s.errInfo("InsertBatchReturning LastInsertId error", query, args))
+		return
+	}
+	numRows, stdErr := res.RowsAffected()
+	if stdErr != nil {
+		err = errs.WrapWithInfo(stdErr, s.errInfo("InsertBatchReturning RowsAffected error", query, args))
+		return
+	}
+	ids = make([]int64, numRows)
+	for i := range ids {
+		ids[i] = firstId + int64(i)*step
+	}
+	return
+}
+
+// autoIncrementStep returns the server's @@auto_increment_increment, which is queried once per shard
+func (s *Shard) autoIncrementStep() (step int64, err errs.Err) {
+	if s.autoIncrement != nil {
+		if step = atomic.LoadInt64(s.autoIncrement); step != 0 {
+			return
+		}
+	}
+	step, err = s.SelectInt("SELECT @@auto_increment_increment")
+	if err != nil {
 		return
 	}
+	if s.autoIncrement != nil {
+		atomic.StoreInt64(s.autoIncrement, step)
+	}
 	return
 }
 
+// Select selects all rows into output, which should be a pointer to a slice of
+// struct pointers (*[]*Person), structs (*[]Person), or for single-column
+// queries, plain values (*[]int64).
 func (s *Shard) Select(output interface{}, query string, args ...interface{}) errs.Err {
-	// Check types
+	return s.SelectContext(context.Background(), output, query, args...)
+}
+
+// SelectContext is Select with a context. See QueryContext.
+func (s *Shard) SelectContext(ctx context.Context, output interface{}, query string, args ...interface{}) errs.Err {
+	outputReflection, err := s.selectOutput(output, query, args)
+	if err != nil {
+		return err
+	}
+
+	// Query DB
+	rows, err := s.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return s.scanRows(outputReflection, rows.Rows, query, args)
+}
+
+// SelectMapBy selects rows into output, which should be a pointer to a map of
+// struct pointers or structs, keyed by the given field or by the field of the given column:
+//
+//	var peopleById map[int64]*Person
+//	err := shard.SelectMapBy(&peopleById, "Id", "SELECT * FROM Person WHERE Id IN (?, ?)", 1, 2)
+//	err = shard.SelectMapBy(&peopleById, "person_id", "SELECT person_id, name FROM person")
+func (s *Shard) SelectMapBy(output interface{}, keyField string, query string, args ...interface{}) errs.Err {
+	outputPtr := reflect.ValueOf(output)
+	if outputPtr.Kind() != reflect.Ptr || outputPtr.Elem().Kind() != reflect.Map {
+		return errs.New(s.errInfo("SelectMapBy expects a pointer to a map of items", query, args))
+	}
+	mapVal := outputPtr.Elem()
+	mapType := mapVal.Type()
+	structType := mapType.Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return errs.New(s.errInfo("SelectMapBy expects map values to be structs or struct pointers", query, args))
+	}
+	field, found := structType.FieldByName(keyField)
+	if !found {
+		keyColumn := s.findStructColumn(structType, keyField)
+		if keyColumn == nil {
+			return errs.New(s.errInfo("SelectMapBy key field not found: "+keyField, query, args))
+		}
+		field = keyColumn.field
+	}
+	if !field.Type.Comparable() || !field.Type.AssignableTo(mapType.Key()) {
+		return errs.New(s.errInfo("SelectMapBy key field "+keyField+" must be comparable and match the map key type", query, args))
+	}
+
+	items := reflect.New(reflect.SliceOf(mapType.Elem()))
+	err := s.Select(items.Interface(), query, args...)
+	if err != nil {
+		return err
+	}
+	if mapVal.IsNil() {
+		mapVal.Set(reflect.MakeMapWithSize(mapType, items.Elem().Len()))
+	}
+	for i := 0; i < items.Elem().Len(); i++ {
+		item := items.Elem().Index(i)
+		mapVal.SetMapIndex(reflect.Indirect(item).FieldByIndex(field.Index), item)
+	}
+	return nil
+}
+
+const selectTypeError = "fun/sql.Select: expects a pointer to a slice of struct pointers, structs or column values, e.g var people []*Person; shard.Select(&people, sql)"
+
+// selectOutput checks that output is a pointer to an empty slice, and returns the slice
+func (s *Shard) selectOutput(output interface{}, query string, args []interface{}) (outputReflection reflect.Value, err errs.Err) {
 	var outputPtr = reflect.ValueOf(output)
 	if outputPtr.Kind() != reflect.Ptr {
-		return errs.New(errInfo("Select expects a pointer to a slice of items", query, args))
+		err = errs.New(s.errInfo("Select expects a pointer to a slice of items", query, args))
+		return
 	}
-	var outputReflection = reflect.Indirect(outputPtr)
+	outputReflection = reflect.Indirect(outputPtr)
 	if outputReflection.Kind() != reflect.Slice {
-		return errs.New(errInfo("Select expects items to be a slice", query, args))
+		err = errs.New(s.errInfo("Select expects items to be a slice", query, args))
+		return
 	}
 	if outputReflection.Len() != 0 {
-		return errs.New(errInfo("Select expects items to be empty", query, args))
+		err = errs.New(s.errInfo("Select expects items to be empty", query, args))
+		return
+	}
+	switch elemType := outputReflection.Type().Elem(); elemType.Kind() {
+	case reflect.Ptr:
+		if elemType.Elem().Kind() != reflect.Struct {
+			err = errs.New(s.errInfo(selectTypeError, query, args, errs.Info{"OutputType": outputPtr.Type().String()}))
+			return
+		}
+	case reflect.Map, reflect.Chan, reflect.Func, reflect.Array, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		err = errs.New(s.errInfo(selectTypeError, query, args, errs.Info{"OutputType": outputPtr.Type().String()}))
+		return
 	}
 	outputReflection.Set(reflect.MakeSlice(outputReflection.Type(), 0, 0))
+	return
+}
 
-	// Query DB
-	var rows, err = s.Query(query, args...)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
+// scanRows scans the rows of the current result set onto the output slice
+func (s *Shard) scanRows(outputReflection reflect.Value, rows *sql.Rows, query string, args []interface{}) (err errs.Err) {
 	columns, stdErr := rows.Columns()
 	if stdErr != nil {
-		return errs.Wrap(stdErr, errInfo("Select rows.Columns error", query, args))
+		return errs.WrapWithInfo(stdErr, s.errInfo("Select rows.Columns error", query, args))
 	}
 
 	valType := outputReflection.Type().Elem()
-	isStruct := (valType.Kind() == reflect.Ptr && valType.Elem().Kind() == reflect.Struct)
-	if isStruct {
+	isStructPtr := (valType.Kind() == reflect.Ptr && valType.Elem().Kind() == reflect.Struct)
+	isStruct := (valType.Kind() == reflect.Struct && !isColumnValueType(valType))
+	if isStructPtr || isStruct {
 		// Reflect onto structs
+		structType := valType
+		if isStructPtr {
+			structType = valType.Elem()
+		}
+		scanner := s.newRowScanner(structType, columns)
 		for rows.Next() {
-			structPtrVal := reflect.New(valType.Elem())
+			structPtrVal := reflect.New(structType)
 			outputItemStructVal := structPtrVal.Elem()
-			err = structFromRow(outputItemStructVal, columns, rows, query, args)
+			err = scanner.scan(outputItemStructVal, rows, query, args)
 			if err != nil {
 				return err
 			}
-			outputReflection.Set(reflect.Append(outputReflection, structPtrVal))
+			if isStructPtr {
+				outputReflection.Set(reflect.Append(outputReflection, structPtrVal))
+			} else {
+				outputReflection.Set(reflect.Append(outputReflection, outputItemStructVal))
+			}
 		}
 	} else {
 		if len(columns) != 1 {
-			return errs.New(errInfo("Select expected single column in select statement for slice of non-struct values", query, args))
+			return errs.New(s.errInfo("Select expected single column in select statement for slice of non-struct values", query, args))
 		}
 		for rows.Next() {
 			rawBytes := &sql.RawBytes{}
 			stdErr = rows.Scan(rawBytes)
 			if stdErr != nil {
-				return errs.Wrap(stdErr, errInfo("Select rows.Scan error", query, args))
+				return errs.WrapWithInfo(stdErr, s.errInfo("Select rows.Scan error", query, args))
+			}
+			err = s.limitColumnBytes(columns[0], rawBytes, false, query, args)
+			if err != nil {
+				return err
 			}
 			outputValue := reflect.New(valType).Elem()
-			err = scanColumnValue(columns[0], outputValue, rawBytes, query, args)
+			err = s.checkNull(columns[0], outputValue, rawBytes, false, query, args)
+			if err != nil {
+				return err
+			}
+			err = s.scanColumnValue(columns[0], outputValue, rawBytes, query, args)
 			if err != nil {
 				return err
 			}
@@ -348,30 +1265,150 @@ func (s *Shard) Select(output interface{}, query string, args ...interface{}) er
 		}
 	}
 
+	// rows.Next() returns false both when done and on errors, e.g network errors while streaming rows
 	stdErr = rows.Err()
-	if err != nil {
-		return errs.Wrap(stdErr, errInfo("Select rows.Err() error", query, args))
+	if stdErr != nil {
+		return errs.WrapWithInfo(stdErr, s.errInfo("Select rows.Err() error", query, args))
 	}
 	return nil
 }
 
+// DefaultMaxPageSize caps the pageSize given to SelectPage, unless changed with SetMaxPageSize
+const DefaultMaxPageSize = 1000
+
+// SetMaxPageSize caps the pageSize given to SelectPage. Zero uses DefaultMaxPageSize.
+func (s *Shard) SetMaxPageSize(maxPageSize int) {
+	s.maxPageSize = maxPageSize
+}
+
+// SelectPage selects the given 1-indexed page of the query's results into output,
+// and reports whether there are more results after it.
+func (s *Shard) SelectPage(output interface{}, query string, page, pageSize int, args ...interface{}) (hasNextPage bool, err errs.Err) {
+	if page < 1 || pageSize < 1 {
+		err = errs.New(s.errInfo("SelectPage expects page and pageSize to be positive", query, args,
+			errs.Info{"Page": page, "PageSize": pageSize}))
+		return
+	}
+	maxPageSize := s.maxPageSize
+	if maxPageSize == 0 {
+		maxPageSize = DefaultMaxPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	// Select one extra row to know if there is a next page
+	query = strings.TrimRight(strings.TrimSpace(query), ";") + " LIMIT ? OFFSET ?"
+	err = s.Select(output, query, append(args[:len(args):len(args)], pageSize+1, (page-1)*pageSize)...)
+	if err != nil {
+		return
+	}
+	outputReflection := reflect.ValueOf(output).Elem()
+	if outputReflection.Len() > pageSize {
+		hasNextPage = true
+		outputReflection.Set(outputReflection.Slice(0, pageSize))
+	}
+	return
+}
+
+// SelectLike selects the rows of table whose column contains term into output, a pointer
+// to a slice of structs or struct pointers. Term is matched literally, with EscapeLike and
+// an explicit ESCAPE '\' clause, and the selected columns are those of output's struct type.
+func (s *Shard) SelectLike(output interface{}, table string, column string, term string) errs.Err {
+	args := []interface{}{"%" + EscapeLike(term) + "%"}
+	structType := reflect.TypeOf(output)
+	if structType != nil && structType.Kind() == reflect.Ptr && structType.Elem().Kind() == reflect.Slice {
+		structType = structType.Elem().Elem()
+		if structType.Kind() == reflect.Ptr {
+			structType = structType.Elem()
+		}
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return errs.New(s.errInfo("SelectLike expects a pointer to a slice of structs", table+"."+column, args))
+	}
+	if err := checkIdentifiers([]string{table, column}); err != nil {
+		return err
+	}
+	query := "SELECT " + columnList(structType) + " FROM " + table + " WHERE " + column + " LIKE ?"
+	if s.placeholders == DollarPlaceholders {
+		query += ` ESCAPE '\'`
+	} else {
+		query += ` ESCAPE '\\'` // MySQL string literals use \ escapes
+	}
+	return s.Select(output, query, args...)
+}
+
+// SelectForUpdate is like Select, but locks the selected rows until the transaction ends.
+// It can only be used within Transact.
+func (s *Shard) SelectForUpdate(output interface{}, query string, args ...interface{}) errs.Err {
+	if !s.isTx {
+		return errs.New(s.errInfo("SelectForUpdate is only supported within Transact", query, args))
+	}
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	if !strings.HasSuffix(strings.ToUpper(query), "FOR UPDATE") {
+		query += " FOR UPDATE"
+	}
+	return s.Select(output, query, args...)
+}
+
 const scanOneTypeError = "fun/sql.SelectOne: expects a **struct, e.g var person *Person; c.SelectOne(&person, sql)"
 
 func (s *Shard) SelectOne(output interface{}, query string, args ...interface{}) (err errs.Err) {
-	found, err := s.scanOne(output, query, true, args...)
+	return s.SelectOneContext(context.Background(), output, query, args...)
+}
+
+// SelectOneContext is SelectOne with a context. See QueryContext.
+func (s *Shard) SelectOneContext(ctx context.Context, output interface{}, query string, args ...interface{}) (err errs.Err) {
+	found, err := s.scanOne(ctx, output, query, true, args...)
 	if err != nil {
 		return
 	}
 	if !found {
-		err = errs.New(errInfo("scanOne got no rows", query, args))
+		err = errs.WrapWithInfo(ErrNotFound, s.errInfo("scanOne got no rows", query, args))
 		return
 	}
 	return
 }
+
+// SelectMaybe is SelectOne which reports no rows with found=false rather than with an
+// ErrNotFound error.
 func (s *Shard) SelectMaybe(output interface{}, query string, args ...interface{}) (found bool, err errs.Err) {
-	return s.scanOne(output, query, false, args...)
+	return s.SelectMaybeContext(context.Background(), output, query, args...)
+}
+
+// SelectOneMaybe is the same as SelectMaybe.
+func (s *Shard) SelectOneMaybe(output interface{}, query string, args ...interface{}) (found bool, err errs.Err) {
+	return s.SelectMaybe(output, query, args...)
+}
+
+// SelectMaybeContext is SelectMaybe with a context. See QueryContext.
+func (s *Shard) SelectMaybeContext(ctx context.Context, output interface{}, query string, args ...interface{}) (found bool, err errs.Err) {
+	return s.scanOne(ctx, output, query, false, args...)
+}
+
+// Get selects a single row into a pre-allocated struct, given as a single pointer:
+//
+//	var person Person
+//	found, err := shard.Get(&person, "SELECT * FROM Person WHERE Id=?", id)
+//
+// It is the same as Scan, and reads more naturally to sqlx users.
+func (s *Shard) Get(output interface{}, query string, args ...interface{}) (found bool, err errs.Err) {
+	return s.Scan(output, query, args...)
 }
-func (s *Shard) scanOne(output interface{}, query string, required bool, args ...interface{}) (found bool, err errs.Err) {
+
+// Scan selects a single row into an existing struct, given as a pointer. Struct
+// fields for columns which the query does not return are left untouched.
+func (s *Shard) Scan(output interface{}, query string, args ...interface{}) (found bool, err errs.Err) {
+	outputPtr := reflect.ValueOf(output)
+	if outputPtr.Kind() != reflect.Ptr || outputPtr.IsNil() || outputPtr.Elem().Kind() != reflect.Struct {
+		err = errs.New(s.errInfo("Scan expects a non-nil pointer to a struct", query, args))
+		return
+	}
+	outputPtrPtr := reflect.New(outputPtr.Type())
+	outputPtrPtr.Elem().Set(outputPtr)
+	return s.scanOne(context.Background(), outputPtrPtr.Interface(), query, false, args...)
+}
+
+func (s *Shard) scanOne(ctx context.Context, output interface{}, query string, required bool, args ...interface{}) (found bool, err errs.Err) {
 	// Check types
 	var outputReflectionPtr = reflect.ValueOf(output)
 	if !outputReflectionPtr.IsValid() {
@@ -386,7 +1423,7 @@ func (s *Shard) scanOne(output interface{}, query string, required bool, args ..
 	}
 
 	// Query DB
-	rows, err := s.Query(query, args...)
+	rows, err := s.QueryContext(ctx, query, args...)
 	if err != nil {
 		return
 	}
@@ -395,10 +1432,14 @@ func (s *Shard) scanOne(output interface{}, query string, required bool, args ..
 	// Reflect onto struct
 	columns, stdErr := rows.Columns()
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errInfo("rows.Columns() error", query, args))
+		err = errs.WrapWithInfo(stdErr, s.errInfo("rows.Columns() error", query, args))
 		return
 	}
 	if !rows.Next() {
+		// No rows, or an error while fetching the first row
+		if stdErr = rows.Err(); stdErr != nil {
+			err = errs.WrapWithInfo(stdErr, s.errInfo("scanOne rows.Err() error", query, args))
+		}
 		return
 	}
 
@@ -411,19 +1452,19 @@ func (s *Shard) scanOne(output interface{}, query string, required bool, args ..
 		vStruct = outputReflection.Elem()
 	}
 
-	err = structFromRow(vStruct, columns, rows, query, args)
+	err = s.structFromRow(vStruct, columns, rows.Rows, query, args)
 	if err != nil {
 		return
 	}
 
 	if rows.Next() {
-		err = errs.New(errInfo("scanOne got multiple rows", query, args))
+		err = errs.New(s.errInfo("scanOne got multiple rows", query, args))
 		return
 	}
 
 	stdErr = rows.Err()
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errInfo("scanOne rows.Err() error", query, args))
+		err = errs.WrapWithInfo(stdErr, s.errInfo("scanOne rows.Err() error", query, args))
 		return
 	}
 
@@ -431,32 +1472,80 @@ func (s *Shard) scanOne(output interface{}, query string, required bool, args ..
 	return
 }
 
-type scanError struct {
-	err   error
-	query string
+func (s *Shard) structFromRow(outputItemStructVal reflect.Value, columns []string, rows *sql.Rows, query string, args []interface{}) errs.Err {
+	return s.newRowScanner(outputItemStructVal.Type(), columns).scan(outputItemStructVal, rows, query, args)
 }
 
-func (s *scanError) Error() string {
-	return s.err.Error() + " [SQL: " + s.query + "]"
+// rowScanner scans rows onto structs of one type. The column to field mapping and the
+// scan destinations are computed once per query, and reused for every row.
+type rowScanner struct {
+	shard         *Shard
+	columns       []string
+	structColumns []*structColumn // nil for columns without a corresponding struct field
+	vals          []interface{}
+	allStrings    bool // Whether all fields are plain strings, which are scanned without type checks
 }
 
-func structFromRow(outputItemStructVal reflect.Value, columns []string, rows *sql.Rows, query string, args []interface{}) errs.Err {
-	vals := make([]interface{}, len(columns))
-	for i, _ := range columns {
-		vals[i] = &sql.RawBytes{}
+func (s *Shard) newRowScanner(structType reflect.Type, columns []string) *rowScanner {
+	r := &rowScanner{s, columns, make([]*structColumn, len(columns)), make([]interface{}, len(columns)), false}
+	r.allStrings = !s.strictNull && s.maxColumnBytes == 0
+	for i, column := range columns {
+		r.vals[i] = &sql.RawBytes{}
+		r.structColumns[i] = s.findStructColumn(structType, column)
+		if r.structColumns[i] == nil {
+			fmt.Println("Warning: no corresponding struct field found for column: " + column)
+		} else if !isPlainStringColumn(r.structColumns[i]) {
+			r.allStrings = false
+		}
 	}
-	stdErr := rows.Scan(vals...)
+	return r
+}
+
+// isPlainStringColumn returns true for string fields without scanning options or custom unmarshalling
+func isPlainStringColumn(column *structColumn) bool {
+	fieldType := column.field.Type
+	return fieldType.Kind() == reflect.String && len(column.opts) == 0 &&
+		!isColumnValueType(fieldType)
+}
+
+func (r *rowScanner) scan(outputItemStructVal reflect.Value, rows *sql.Rows, query string, args []interface{}) errs.Err {
+	stdErr := rows.Scan(r.vals...)
 	if stdErr != nil {
-		return errs.Wrap(stdErr, errInfo("structFromRow error", query, args))
+		return errs.WrapWithInfo(stdErr, r.shard.errInfo("structFromRow error", query, args))
 	}
 
-	for i, column := range columns {
-		structFieldValue := outputItemStructVal.FieldByName(column)
-		if !structFieldValue.IsValid() {
-			fmt.Println("Warning: no corresponding struct field found for column: " + column)
+	if r.allStrings {
+		for i, structColumn := range r.structColumns {
+			if rawBytes := *r.vals[i].(*sql.RawBytes); structColumn != nil && rawBytes != nil {
+				outputItemStructVal.FieldByIndex(structColumn.field.Index).SetString(string(rawBytes))
+			}
+		}
+		return nil
+	}
+
+	for i, column := range r.columns {
+		structColumn := r.structColumns[i]
+		if structColumn == nil {
 			continue
 		}
-		err := scanColumnValue(column, structFieldValue, vals[i].(*sql.RawBytes), query, args)
+		structFieldValue := outputItemStructVal.FieldByIndex(structColumn.field.Index)
+		rawBytes := r.vals[i].(*sql.RawBytes)
+		err := r.shard.limitColumnBytes(column, rawBytes, structColumn.opts["truncate"], query, args)
+		if err != nil {
+			return err
+		}
+		err = r.shard.checkNull(column, structFieldValue, rawBytes, structColumn.opts["notnull"], query, args)
+		if err != nil {
+			return err
+		}
+		switch {
+		case structColumn.opts["csv"]:
+			err = r.shard.scanCSVColumnValue(column, structFieldValue, rawBytes, query, args)
+		case structColumn.opts["json"]:
+			err = r.shard.scanJSONColumnValue(column, structFieldValue, rawBytes, query, args)
+		default:
+			err = r.shard.scanColumnValue(column, structFieldValue, rawBytes, query, args)
+		}
 		if err != nil {
 			return err
 		}
@@ -465,45 +1554,277 @@ func structFromRow(outputItemStructVal reflect.Value, columns []string, rows *sq
 	return nil
 }
 
-func scanColumnValue(column string, reflectVal reflect.Value, value *sql.RawBytes, query string, args []interface{}) errs.Err {
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isScannerType reports whether fields of the given type are scanned via their Scan method
+// rather than by kind, e.g sql.NullString, or custom id, enum and uuid types.
+func isScannerType(typ reflect.Type) bool {
+	return reflect.PtrTo(typ).Implements(scannerType)
+}
+
+// findStructColumn finds the struct field for the given column: first by the fields'
+// tagged column names, then by the column mapper, and then by the exact column name.
+// With the default mapper, snake_case columns also match fields regardless of how
+// initialisms are cased, e.g user_id matches both UserID and UserId. Fields with a
+// tagged column name only match that name, never their Go field name.
+func (s *Shard) findStructColumn(structType reflect.Type, column string) *structColumn {
+	for _, structColumn := range structColumns(structType) {
+		if structColumn.tagged && structColumn.name == column {
+			return &structColumn
+		}
+	}
+	mapper := s.columnMapper
+	if mapper == nil {
+		mapper = CamelCaseColumn
+	}
+	field, found := structType.FieldByName(mapper(column))
+	if !found {
+		field, found = structType.FieldByName(column)
+	}
+	if !found && s.columnMapper == nil {
+		unsnaked := strings.ReplaceAll(column, "_", "")
+		field, found = structType.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, unsnaked) })
+	}
+	if !found || isSkippedField(field) {
+		return nil
+	}
+	structColumn := newStructColumn(field)
+	if structColumn.tagged {
+		return nil
+	}
+	return &structColumn
+}
+
+// scanCSVColumnValue scans a comma separated list onto a slice field, for fields tagged `fun:",csv"`
+func (s *Shard) scanCSVColumnValue(column string, reflectVal reflect.Value, value *sql.RawBytes, query string, args []interface{}) errs.Err {
+	if *value == nil {
+		return nil // Leave struct field empty
+	}
+	if reflectVal.Kind() != reflect.Slice {
+		return errs.New(s.errInfo("csv column "+column+" expects a slice field", query, args))
+	}
+	var parts []string
+	if len(*value) > 0 {
+		parts = strings.Split(string(*value), ",")
+	}
+	sliceVal := reflect.MakeSlice(reflectVal.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		partBytes := sql.RawBytes(strings.TrimSpace(part))
+		err := s.scanColumnValue(column, sliceVal.Index(i), &partBytes, query, args)
+		if err != nil {
+			return err
+		}
+	}
+	reflectVal.Set(sliceVal)
+	return nil
+}
+
+// scanJSONColumnValue unmarshals JSON onto a field, for fields tagged `fun:",json"`
+func (s *Shard) scanJSONColumnValue(column string, reflectVal reflect.Value, value *sql.RawBytes, query string, args []interface{}) errs.Err {
+	if *value == nil {
+		return nil // Leave struct field empty
+	}
+	stdErr := json.Unmarshal(*value, reflectVal.Addr().Interface())
+	if stdErr != nil {
+		return errs.WrapWithInfo(stdErr, s.errInfo("json.Unmarshal error for column "+column, query, args, errs.Info{"Bytes": string(*value)}))
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isColumnValueType reports whether values of the given struct type are scanned
+// from a single column, rather than having their fields scanned from a row.
+func isColumnValueType(typ reflect.Type) bool {
+	return isScannerType(typ) || reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+// SetTimeLocation sets the location of DATETIME and DATE columns, which have no time zone.
+// It should match the time zone which the database stores them in. Nil uses UTC.
+func (s *Shard) SetTimeLocation(location *time.Location) {
+	s.timeLocation = location
+}
+
+// timeLayouts are the text formats of DATETIME, TIMESTAMP and DATE columns, and of
+// time.Time values converted to text by database/sql, e.g with MySQL's parseTime=true.
+var timeLayouts = []string{"2006-01-02 15:04:05.999999999", "2006-01-02", time.RFC3339Nano}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// parseTime parses a time column. MySQL's zero dates, e.g 0000-00-00, give a zero time.
+func (s *Shard) parseTime(column string, str string, query string, args []interface{}) (time.Time, errs.Err) {
+	if strings.HasPrefix(str, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	location := s.timeLocation
+	if location == nil {
+		location = time.UTC
+	}
+	for _, layout := range timeLayouts {
+		if timeVal, stdErr := time.ParseInLocation(layout, str, location); stdErr == nil {
+			return timeVal, nil
+		}
+	}
+	return time.Time{}, errs.New(s.errInfo("Bad time value for column "+column, query, args, errs.Info{"Value": str}))
+}
+
+// inferColumnValue returns an int64 or float64 if bytes parse as one, and otherwise a string.
+// It is used for interface{} fields, e.g the values of a generic key-value table.
+func inferColumnValue(bytes []byte) interface{} {
+	str := string(bytes)
+	if intVal, stdErr := strconv.ParseInt(str, 10, 64); stdErr == nil {
+		return intVal
+	}
+	if floatVal, stdErr := strconv.ParseFloat(str, 64); stdErr == nil {
+		return floatVal
+	}
+	return str
+}
+
+func (s *Shard) scanColumnValue(column string, reflectVal reflect.Value, value *sql.RawBytes, query string, args []interface{}) errs.Err {
 	bytes := []byte(*value)
+	if isScannerType(reflectVal.Type()) {
+		var src interface{}
+		if reflectVal.Type() == nullTimeType && bytes != nil {
+			timeVal, err := s.parseTime(column, string(bytes), query, args)
+			if err != nil {
+				return err
+			}
+			src = timeVal
+		} else if bytes != nil {
+			// RawBytes are only valid until the next rows.Next(), so scanners get a copy
+			src = append([]byte{}, bytes...)
+		}
+		stdErr := reflectVal.Addr().Interface().(sql.Scanner).Scan(src)
+		if stdErr != nil {
+			return errs.WrapWithInfo(stdErr, s.errInfo("Scan error for column "+column, query, args, errs.Info{"Bytes": bytes}))
+		}
+		return nil
+	}
+	if reflectVal.Kind() == reflect.Ptr {
+		// Pointer fields are nil for NULL, e.g *string or *int64 to distinguish NULL from zero values
+		if bytes == nil {
+			reflectVal.Set(reflect.Zero(reflectVal.Type()))
+			return nil
+		}
+		elemVal := reflect.New(reflectVal.Type().Elem())
+		err := s.scanColumnValue(column, elemVal.Elem(), value, query, args)
+		if err != nil {
+			return err
+		}
+		reflectVal.Set(elemVal)
+		return nil
+	}
 	if bytes == nil {
 		return nil // Leave struct field empty
 	}
+	if reflectVal.Type() == timeType {
+		timeVal, err := s.parseTime(column, string(bytes), query, args)
+		if err != nil {
+			return err
+		}
+		reflectVal.Set(reflect.ValueOf(timeVal))
+		return nil
+	}
+	if reflectVal.Addr().Type().Implements(textUnmarshalerType) {
+		// E.g exact decimal types for DECIMAL columns. DECIMAL columns can also be
+		// scanned into string fields to avoid float precision loss.
+		stdErr := reflectVal.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(bytes)
+		if stdErr != nil {
+			return errs.WrapWithInfo(stdErr, s.errInfo("UnmarshalText error for column "+column, query, args, errs.Info{"Bytes": bytes}))
+		}
+		return nil
+	}
 	switch reflectVal.Kind() {
 	case reflect.String:
 		reflectVal.SetString(string(bytes))
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		uintVal, stdErr := strconv.ParseUint(string(bytes), 10, 64)
 		if stdErr != nil {
-			return errs.Wrap(stdErr, errInfo("strconv.ParseUint error", query, args, errs.Info{"Bytes": bytes}))
+			return errs.WrapWithInfo(stdErr, s.errInfo("strconv.ParseUint error", query, args, errs.Info{"Bytes": bytes}))
 		}
-		reflectVal.SetUint(reflect.ValueOf(uintVal).Uint())
+		if reflectVal.OverflowUint(uintVal) {
+			return errs.New(s.errInfo("Value overflows "+reflectVal.Type().String()+" field for column "+column, query, args, errs.Info{"Bytes": bytes}))
+		}
+		reflectVal.SetUint(uintVal)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		intVal, stdErr := strconv.ParseInt(string(bytes), 10, 64)
 		if stdErr != nil {
-			return errs.Wrap(stdErr, errInfo("strconv.ParseInt error", query, args, errs.Info{"Bytes": bytes}))
+			return errs.WrapWithInfo(stdErr, s.errInfo("strconv.ParseInt error", query, args, errs.Info{"Bytes": bytes}))
 		}
-		reflectVal.SetInt(reflect.ValueOf(intVal).Int())
-	case reflect.Bool:
-		boolVal, stdErr := strconv.ParseBool(string(bytes))
+		if reflectVal.OverflowInt(intVal) {
+			return errs.New(s.errInfo("Value overflows "+reflectVal.Type().String()+" field for column "+column, query, args, errs.Info{"Bytes": bytes}))
+		}
+		reflectVal.SetInt(intVal)
+	case reflect.Float32, reflect.Float64:
+		floatVal, stdErr := strconv.ParseFloat(string(bytes), reflectVal.Type().Bits())
 		if stdErr != nil {
-			return errs.Wrap(stdErr, errInfo("strconv.ParseBool error", query, args, errs.Info{"Bytes": bytes}))
+			return errs.WrapWithInfo(stdErr, s.errInfo("strconv.ParseFloat error for column "+column, query, args, errs.Info{"Bytes": bytes}))
+		}
+		reflectVal.SetFloat(floatVal)
+	case reflect.Bool:
+		// BOOL and TINYINT(1) columns are true for any non-zero value, and BIT(1) columns are a single 0 or 1 byte
+		if len(bytes) == 1 && bytes[0] <= 1 {
+			reflectVal.SetBool(bytes[0] == 1)
+		} else if intVal, stdErr := strconv.ParseInt(string(bytes), 10, 64); stdErr == nil {
+			reflectVal.SetBool(intVal != 0)
+		} else if boolVal, stdErr := strconv.ParseBool(string(bytes)); stdErr == nil {
+			reflectVal.SetBool(boolVal)
+		} else {
+			return errs.WrapWithInfo(stdErr, s.errInfo("strconv.ParseBool error", query, args, errs.Info{"Bytes": bytes}))
 		}
-		reflectVal.SetBool(reflect.ValueOf(boolVal).Bool())
+	case reflect.Interface:
+		if reflectVal.NumMethod() != 0 {
+			return errs.New(s.errInfo("Bad row value for column "+column+": "+reflectVal.Type().String(), query, args))
+		}
+		reflectVal.Set(reflect.ValueOf(inferColumnValue(bytes)))
 	default:
-		if reflectVal.Kind() == reflect.Slice { // && reflectVal. == reflect.Uint8 {
-			// byte slice
-			reflectVal.SetBytes(bytes)
+		if reflectVal.Kind() == reflect.Slice && reflectVal.Type().Elem().Kind() == reflect.Uint8 {
+			// Byte slice. RawBytes are only valid until the next rows.Next(), so copy them
+			reflectVal.SetBytes(append([]byte{}, bytes...))
+		} else if reflectVal.Kind() == reflect.Array && reflectVal.Type().Elem().Kind() == reflect.Uint8 {
+			// Fixed size bytes, e.g [16]byte for BINARY(16) UUIDs
+			if len(bytes) != reflectVal.Len() {
+				return errs.New(s.errInfo("Wrong number of bytes for "+reflectVal.Type().String()+" field for column "+column, query, args, errs.Info{"Bytes": bytes}))
+			}
+			reflect.Copy(reflectVal, reflect.ValueOf(bytes))
+		} else if kind := reflectVal.Kind(); kind == reflect.Struct || kind == reflect.Map || kind == reflect.Slice {
+			// E.g JSON columns. Fields of other types can be tagged `fun:",json"`
+			return s.scanJSONColumnValue(column, reflectVal, value, query, args)
 		} else {
-			return errs.New(errInfo("Bad row value for column "+column+": "+reflectVal.Kind().String(), query, args))
+			return errs.New(s.errInfo("Bad row value for column "+column+": "+reflectVal.Kind().String(), query, args))
 		}
 	}
 	return nil
 }
 
-func errInfo(description, query string, args []interface{}, infos ...errs.Info) errs.Info {
+// SetErrInfoLimits keeps the query and args info of the shard's errors small for logging.
+// Queries longer than maxQueryLength bytes are truncated, and zero keeps the full query.
+// With omitArgs, errors carry the number of args instead of the args themselves.
+func (s *Shard) SetErrInfoLimits(maxQueryLength int, omitArgs bool) {
+	s.errQueryLength = maxQueryLength
+	s.errOmitArgs = omitArgs
+}
+
+// errInfo returns the structured info of an error, which carries its query and args
+// separately from its short Description.
+func (s *Shard) errInfo(description, query string, args []interface{}, infos ...errs.Info) errs.Info {
 	info := errs.Info{"Description": description, "Query": query, "Args": args}
+	if s.errQueryLength > 0 && len(query) > s.errQueryLength {
+		end := s.errQueryLength
+		for end > 0 && !utf8.RuneStart(query[end]) {
+			end -= 1 // Don't cut a multi-byte character in half
+		}
+		info["Query"] = query[:end] + "..."
+		info["QueryLength"] = len(query)
+	}
+	if s.errOmitArgs {
+		delete(info, "Args")
+		info["NumArgs"] = len(args)
+	}
 	for _, moreInfo := range infos {
 		for key, val := range moreInfo {
 			info[key] = val
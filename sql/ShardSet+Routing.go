@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"database/sql"
+	"reflect"
+	"sync"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// ShardFunc returns the id of the shard which a query with the given args should
+// run on, e.g the id of the user whose rows the query selects. See ShardSet.Shard.
+type ShardFunc func(args []interface{}) int64
+
+// SetShardFunc sets the function which Query, Exec, Select and SelectOne use to route
+// each query to a shard by its args.
+func (s *ShardSet) SetShardFunc(shardFunc ShardFunc) {
+	s.shardFunc = shardFunc
+}
+
+// RoutedShard returns the shard which a query with the given args is routed to.
+// It errors if no shard func has been set with SetShardFunc.
+func (s *ShardSet) RoutedShard(args []interface{}) (*Shard, errs.Err) {
+	if s.shardFunc == nil {
+		return nil, errs.New(errs.Info{"Description": "ShardSet has no shard func - call SetShardFunc before routing queries"})
+	}
+	return s.Shard(s.shardFunc(args)), nil
+}
+
+func (s *ShardSet) Query(query string, args ...interface{}) (*Rows, errs.Err) {
+	shard, err := s.RoutedShard(args)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Query(query, args...)
+}
+
+func (s *ShardSet) Exec(query string, args ...interface{}) (sql.Result, errs.Err) {
+	shard, err := s.RoutedShard(args)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Exec(query, args...)
+}
+
+func (s *ShardSet) Select(output interface{}, query string, args ...interface{}) errs.Err {
+	shard, err := s.RoutedShard(args)
+	if err != nil {
+		return err
+	}
+	return shard.Select(output, query, args...)
+}
+
+func (s *ShardSet) SelectOne(output interface{}, query string, args ...interface{}) errs.Err {
+	shard, err := s.RoutedShard(args)
+	if err != nil {
+		return err
+	}
+	return shard.SelectOne(output, query, args...)
+}
+
+// Broadcast runs a select on all shards in parallel, and appends the rows of every
+// shard onto output in shard order, e.g for global aggregates:
+//
+//	var counts []int64
+//	err := shardSet.Broadcast(&counts, "SELECT COUNT(*) FROM Person")
+//
+// It returns the error of the first shard which fails.
+func (s *ShardSet) Broadcast(output interface{}, query string, args ...interface{}) errs.Err {
+	if len(s.shards) == 0 {
+		return errs.New(errs.Info{"Description": "Broadcast on a shard set without shards", "Query": query})
+	}
+	outputReflection, err := s.shards[0].selectOutput(output, query, args)
+	if err != nil {
+		return err
+	}
+	shardOutputs := make([]reflect.Value, len(s.shards))
+	shardErrs := make([]errs.Err, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func(i int, shard *Shard) {
+			defer wg.Done()
+			shardOutputs[i] = reflect.New(outputReflection.Type())
+			// Select fixes args in place, so each shard gets its own copy
+			shardArgs := append([]interface{}(nil), args...)
+			shardErrs[i] = shard.Select(shardOutputs[i].Interface(), query, shardArgs...)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	for i := range s.shards {
+		if shardErrs[i] != nil {
+			return shardErrs[i]
+		}
+		outputReflection.Set(reflect.AppendSlice(outputReflection, shardOutputs[i].Elem()))
+	}
+	return nil
+}
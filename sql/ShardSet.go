@@ -1,10 +1,12 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/marcuswestin/fun-go/errs"
 	"github.com/marcuswestin/fun-go/random"
@@ -20,6 +22,12 @@ type ShardSet struct {
 	maxShards    int
 	maxConns     int
 	shards       []*Shard
+	columnMapper func(column string) string
+	charset      string
+	collation    string
+	shardFunc    ShardFunc
+	placeholders PlaceholderStyle
+	connVars     ConnVariables
 }
 
 func NewShardSet(username string, password string, host string, port int, dbNamePrefix string, numShards int, maxShards int, maxConns int) *ShardSet {
@@ -32,9 +40,41 @@ func NewShardSet(username string, password string, host string, port int, dbName
 		numShards:    numShards,
 		maxShards:    maxShards,
 		maxConns:     maxConns,
+		charset:      "utf8mb4",
+		collation:    "utf8_unicode_ci",
 	}
 }
 
+// SetCharset sets the charset and collation of all connections, which the driver
+// sets with SET NAMES whenever it opens a connection, including when reconnecting.
+// It must be called before Connect.
+func (s *ShardSet) SetCharset(charset string, collation string) {
+	s.charset = charset
+	s.collation = collation
+}
+
+// SetConnVariables adds connection variables which are passed to the opener along with
+// the default ones, e.g "sslmode" for Postgres. Given variables override default ones.
+// It must be called before Connect.
+func (s *ShardSet) SetConnVariables(connVars ConnVariables) {
+	if s.connVars == nil {
+		s.connVars = ConnVariables{}
+	}
+	for name, val := range connVars {
+		s.connVars[name] = val
+	}
+}
+
+// NewShardSetFromDBs returns a connected shard set of already opened dbs, e.g dbs
+// of other drivers than the one set with SetOpener, or dbs of mock drivers in tests.
+func NewShardSetFromDBs(dbs []*sql.DB) *ShardSet {
+	s := &ShardSet{numShards: len(dbs), maxShards: len(dbs), shards: make([]*Shard, len(dbs))}
+	for i, db := range dbs {
+		s.shards[i] = NewShard(fmt.Sprint("shard", i+1), db)
+	}
+	return s
+}
+
 func (s *ShardSet) Connect() (err errs.Err) {
 	s.shards = make([]*Shard, s.numShards)
 	for i := 0; i < s.numShards; i++ {
@@ -46,6 +86,23 @@ func (s *ShardSet) Connect() (err errs.Err) {
 	return
 }
 
+// SetColumnMapper sets the column mapper of all shards. See Shard.SetColumnMapper.
+func (s *ShardSet) SetColumnMapper(mapper func(column string) string) {
+	s.columnMapper = mapper
+	for _, shard := range s.shards {
+		shard.SetColumnMapper(mapper)
+	}
+}
+
+// SetPlaceholderStyle sets the placeholder style of all shards, e.g DollarPlaceholders
+// for Postgres. See Shard.SetPlaceholderStyle.
+func (s *ShardSet) SetPlaceholderStyle(style PlaceholderStyle) {
+	s.placeholders = style
+	for _, shard := range s.shards {
+		shard.SetPlaceholderStyle(style)
+	}
+}
+
 func (s *ShardSet) Shard(id int64) *Shard {
 	if id == 0 {
 		panic("Bad shard index id 0")
@@ -62,6 +119,51 @@ func (s *ShardSet) All() []*Shard {
 	return all
 }
 
+// Ping pings every shard in the set and returns the first error.
+func (s *ShardSet) Ping() errs.Err {
+	return s.PingContext(context.Background())
+}
+
+func (s *ShardSet) PingContext(ctx context.Context) errs.Err {
+	for _, shard := range s.shards {
+		if err := shard.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartPeriodicPings starts periodic pings of all shards and returns the first error.
+// See Shard.StartPeriodicPing.
+func (s *ShardSet) StartPeriodicPings(interval time.Duration, onPingError PingErrorFunc) errs.Err {
+	for _, shard := range s.shards {
+		if err := shard.StartPeriodicPing(interval, onPingError); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all shards and returns the first error.
+func (s *ShardSet) Close() (err errs.Err) {
+	for _, shard := range s.shards {
+		if closeErr := shard.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
+
+// Drain drains all shards. See Shard.Drain.
+func (s *ShardSet) Drain(ctx context.Context) (err errs.Err) {
+	for _, shard := range s.shards {
+		if drainErr := shard.Drain(ctx); drainErr != nil && err == nil {
+			err = drainErr
+		}
+	}
+	return
+}
+
 func (s *ShardSet) RandomShard() *Shard {
 	return s.shards[random.Between(0, len(s.shards))]
 }
@@ -80,12 +182,15 @@ func newShard(s *ShardSet, dbName string, autoIncrementOffset int) (*Shard, errs
 	connVars := ConnVariables{
 		"autocommit":               "true",
 		"clientFoundRows":          "true",
-		"charset":                  "utf8mb4",
-		"collation":                "utf8_unicode_ci",
+		"charset":                  s.charset,
+		"collation":                s.collation,
 		"auto_increment_increment": strconv.Itoa(s.maxShards),
 		"auto_increment_offset":    strconv.Itoa(autoIncrementOffset),
 		"sql_mode":                 "STRICT_ALL_TABLES",
 	}
+	for name, val := range s.connVars {
+		connVars[name] = val
+	}
 
 	db, err := dbOpener(s.username, s.password, dbName, s.host, s.port, connVars)
 	if err != nil {
@@ -96,9 +201,12 @@ func newShard(s *ShardSet, dbName string, autoIncrementOffset int) (*Shard, errs
 	// db.SetMaxIdleConns(n)
 	stdErr := db.Ping()
 	if stdErr != nil {
-		return nil, errs.Wrap(stdErr, nil)
+		return nil, errs.WrapWithInfo(stdErr, nil)
 	}
-	return &Shard{dbName, db, db}, nil
+	shard := NewShard(dbName, db)
+	shard.SetColumnMapper(s.columnMapper)
+	shard.SetPlaceholderStyle(s.placeholders)
+	return shard, nil
 }
 
 func SetOpener(opener Opener) {
@@ -0,0 +1,32 @@
+package sql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShardSetRouting(t *testing.T) {
+	shardSet := &ShardSet{maxShards: 2, shards: []*Shard{
+		newFakeShard("TestShardSetRouting1", threePeople),
+		newFakeShard("TestShardSetRouting2", &fakeDB{columns: threePeople.columns, rows: threePeople.rows[:1]}),
+	}}
+	shardSet.SetShardFunc(func(args []interface{}) int64 { return args[0].(int64) })
+	var people []*person
+	assert(t, shardSet.Select(&people, "SELECT Id, Name FROM Person WHERE ShardId=?", int64(2)) == nil)
+	assert(t, len(people) == 1)
+
+	var allPeople []person
+	assert(t, shardSet.Broadcast(&allPeople, "SELECT Id, Name FROM Person") == nil)
+	assert(t, len(allPeople) == 4 && allPeople[3].Name == "Alice")
+
+	args := []interface{}{name("Al"), Status(3)}
+	allPeople = nil
+	assert(t, shardSet.Broadcast(&allPeople, "SELECT Id, Name FROM Person WHERE Name=? AND Status=?", args...) == nil)
+	assert(t, len(allPeople) == 4 && args[0] == name("Al") && args[1] == Status(3))
+
+	shardSet.SetShardFunc(nil)
+	err := shardSet.Select(&people, "SELECT Id, Name FROM Person WHERE ShardId=?", int64(2))
+	assert(t, err != nil && strings.HasPrefix(err.InternalInfo()["Description"].(string), "ShardSet has no shard func"))
+	_, err = shardSet.Exec("DELETE FROM Person WHERE ShardId=?", int64(2))
+	assert(t, err != nil)
+}
@@ -0,0 +1,1144 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+type person struct {
+	Id   int64
+	Name string
+}
+
+var threePeople = &fakeDB{
+	columns: []string{"Id", "Name"},
+	rows:    [][]driver.Value{{"1", "Alice"}, {"2", "Bob"}, {"3", "Cat"}},
+}
+
+func TestSavepoints(t *testing.T) {
+	fake := &fakeDB{}
+	shard := newFakeShard("TestSavepoints", fake)
+	err := shard.Transact(func(tx *Shard) errs.Err {
+		assert(t, tx.Savepoint("before_items") == nil && fake.lastExec == "SAVEPOINT before_items")
+		assert(t, tx.RollbackTo("before_items") == nil && fake.lastExec == "ROLLBACK TO SAVEPOINT before_items")
+		assert(t, tx.ReleaseSavepoint("before_items") == nil && fake.lastExec == "RELEASE SAVEPOINT before_items")
+		assert(t, tx.Savepoint("x; DROP TABLE Person") != nil)
+		return nil
+	})
+	assert(t, err == nil && fake.commits == 1)
+	assert(t, shard.Savepoint("before_items") != nil)
+}
+
+func TestSelectForUpdate(t *testing.T) {
+	fake := &fakeDB{columns: threePeople.columns, rows: threePeople.rows[:1]}
+	shard := newFakeShard("TestSelectForUpdate", fake)
+	err := shard.Transact(func(tx *Shard) errs.Err {
+		var people []*person
+		assert(t, tx.SelectForUpdate(&people, "SELECT Id, Name FROM Person WHERE Id=?;", 1) == nil && len(people) == 1)
+		assert(t, fake.lastQuery == "SELECT Id, Name FROM Person WHERE Id=? FOR UPDATE")
+		var lockedPeople []*person
+		assert(t, tx.SelectForUpdate(&lockedPeople, "SELECT Id, Name FROM Person WHERE Id=? for update", 1) == nil)
+		assert(t, fake.lastQuery == "SELECT Id, Name FROM Person WHERE Id=? for update")
+		return nil
+	})
+	assert(t, err == nil)
+	var people []*person
+	assert(t, shard.SelectForUpdate(&people, "SELECT Id, Name FROM Person WHERE Id=?", 1) != nil)
+}
+
+func TestSelectLike(t *testing.T) {
+	fake := &fakeDB{columns: threePeople.columns, rows: threePeople.rows[:1]}
+	shard := newFakeShard("TestSelectLike", fake)
+	var people []person
+	assert(t, shard.SelectLike(&people, "Person", "Name", "50%_off") == nil && len(people) == 1)
+	assert(t, fake.lastQuery == `SELECT Id, Name FROM Person WHERE Name LIKE ? ESCAPE '\\'`)
+	assert(t, reflect.DeepEqual(fake.lastArgs, []driver.Value{`%50\%\_off%`}))
+	shard.SetPlaceholderStyle(DollarPlaceholders)
+	people = nil
+	assert(t, shard.SelectLike(&people, "Person", "Name", "Al") == nil)
+	assert(t, fake.lastQuery == `SELECT Id, Name FROM Person WHERE Name LIKE $1 ESCAPE '\'`)
+	assert(t, shard.SelectLike(&people, "Person; DROP TABLE Person", "Name", "Al") != nil)
+}
+
+func TestSelectPage(t *testing.T) {
+	// The fake db ignores LIMIT, so its rows are the rows the database would return
+	fake := &fakeDB{columns: threePeople.columns, rows: threePeople.rows}
+	shard := newFakeShard("TestSelectPage", fake)
+	var firstPage []*person
+	hasNextPage, err := shard.SelectPage(&firstPage, "SELECT Id, Name FROM Person WHERE Name > ?;", 1, 2, "A")
+	assert(t, err == nil && hasNextPage && len(firstPage) == 2 && firstPage[1].Name == "Bob")
+	assert(t, fake.lastQuery == "SELECT Id, Name FROM Person WHERE Name > ? LIMIT ? OFFSET ?")
+	assert(t, reflect.DeepEqual(fake.lastArgs, []driver.Value{"A", int64(3), int64(0)}))
+
+	fake.rows = threePeople.rows[2:]
+	var lastPage []*person
+	hasNextPage, err = shard.SelectPage(&lastPage, "SELECT Id, Name FROM Person", 2, 2)
+	assert(t, err == nil && !hasNextPage && len(lastPage) == 1 && lastPage[0].Name == "Cat")
+	assert(t, reflect.DeepEqual(fake.lastArgs, []driver.Value{int64(3), int64(2)}))
+
+	shard.SetMaxPageSize(2)
+	fake.rows = threePeople.rows
+	var clampedPage []*person
+	hasNextPage, err = shard.SelectPage(&clampedPage, "SELECT Id, Name FROM Person", 3, 100)
+	assert(t, err == nil && hasNextPage && len(clampedPage) == 2)
+	assert(t, reflect.DeepEqual(fake.lastArgs, []driver.Value{int64(3), int64(4)}))
+
+	var badPage []*person
+	_, err = shard.SelectPage(&badPage, "SELECT Id, Name FROM Person", 0, 10)
+	assert(t, err != nil)
+}
+
+func TestSlowQueryThreshold(t *testing.T) {
+	shard := newFakeShard("TestSlowQueryThreshold", threePeople)
+	var slowQueries []string
+	var slowCaller string
+	shard.SetSlowQueryThreshold(time.Hour, func(query string, args []interface{}, duration time.Duration, caller string) {
+		slowQueries = append(slowQueries, query)
+		slowCaller = caller
+	})
+	var people []*person
+	assert(t, shard.Select(&people, "SELECT Id, Name FROM Person") == nil && len(slowQueries) == 0)
+
+	shard.SetSlowQueryThreshold(time.Nanosecond, shard.onSlowQuery)
+	_, err := shard.Exec("DELETE FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && len(slowQueries) == 1 && slowQueries[0] == "DELETE FROM Person WHERE Id=?")
+	// Tests are in this package, so the first caller outside of it is the test runner
+	assert(t, strings.Contains(slowCaller, "testing.go:"))
+
+	shard.SetSlowQueryThreshold(0, shard.onSlowQuery)
+	_, err = shard.Exec("DELETE FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && len(slowQueries) == 1)
+}
+
+func TestDrain(t *testing.T) {
+	shard := newFakeShard("TestDrain", threePeople)
+	rows, err := shard.Query("SELECT Id, Name FROM Person")
+	assert(t, err == nil && shard.db.Stats().InUse == 1)
+	drained := make(chan errs.Err)
+	go func() { drained <- shard.Drain(context.Background()) }()
+	for atomic.LoadInt32(&shard.draining) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var people []*person
+	err = shard.Select(&people, "SELECT Id, Name FROM Person")
+	assert(t, err != nil && err.InternalInfo()["Description"] == "Shard is draining")
+	err = shard.Transact(func(tx *Shard) errs.Err { return nil })
+	assert(t, err != nil && err.InternalInfo()["Description"] == "Shard is draining")
+	err = shard.Session(func(session *Shard) errs.Err { return nil })
+	assert(t, err != nil && err.InternalInfo()["Description"] == "Shard is draining")
+	select {
+	case <-drained:
+		t.Error("Drain returned while a connection was in use")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	rows.Close()
+	assert(t, <-drained == nil)
+	assert(t, shard.db.Ping() != nil) // Closed
+}
+
+func TestDrainTimeout(t *testing.T) {
+	shard := newFakeShard("TestDrainTimeout", threePeople)
+	rows, err := shard.Query("SELECT Id, Name FROM Person")
+	assert(t, err == nil)
+	defer rows.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = shard.Drain(ctx)
+	assert(t, errors.Is(err, context.DeadlineExceeded) && err.InternalInfo()["InUse"] == 1)
+}
+
+type friend struct {
+	PersonId int64
+	FriendId int64
+}
+
+func TestCallProc(t *testing.T) {
+	shard := newFakeShard("TestCallProc", &fakeDB{
+		columns: threePeople.columns,
+		rows:    threePeople.rows[:1],
+		nextResults: []*fakeDB{{
+			columns: []string{"PersonId", "FriendId"},
+			rows:    [][]driver.Value{{"1", "2"}, {"1", "3"}},
+		}},
+	})
+	res, err := shard.CallProc("CALL GetPersonAndFriends(?)", 1)
+	assert(t, err == nil)
+	defer res.Close()
+	var people []*person
+	assert(t, res.Select(&people) == nil && len(people) == 1 && people[0].Name == "Alice")
+	assert(t, res.NextResultSet())
+	var friends []friend
+	assert(t, res.Select(&friends) == nil && len(friends) == 2 && friends[1].FriendId == 3)
+	assert(t, !res.NextResultSet() && res.Err() == nil)
+	assert(t, res.Close() == nil)
+}
+
+type fakeMetrics struct {
+	queries   int
+	errors    int
+	poolStats sql.DBStats
+}
+
+func (m *fakeMetrics) ObserveQuery(dbName string, duration time.Duration, err error) {
+	m.queries += 1
+	if err != nil {
+		m.errors += 1
+	}
+}
+func (m *fakeMetrics) SetPoolStats(dbName string, stats sql.DBStats) { m.poolStats = stats }
+
+func TestMetrics(t *testing.T) {
+	shard := newFakeShard("TestMetrics", threePeople)
+	shard.db.SetMaxOpenConns(3)
+	metrics := &fakeMetrics{}
+	shard.SetMetrics(metrics)
+	var people []*person
+	assert(t, shard.Select(&people, "SELECT Id, Name FROM Person") == nil)
+	_, err := shard.Exec("DELETE FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && metrics.queries == 2 && metrics.errors == 0)
+	assert(t, metrics.poolStats.MaxOpenConnections == 3)
+
+	// The driver cannot convert channel args
+	_, err = shard.Exec("DELETE FROM Person WHERE Id=?", make(chan int))
+	assert(t, err != nil && metrics.queries == 3 && metrics.errors == 1)
+}
+
+func TestQueryWatchdog(t *testing.T) {
+	fake := &fakeDB{delay: 50 * time.Millisecond}
+	shard := newFakeShard("TestQueryWatchdog", fake)
+	stuckQueries := make(chan string, 10)
+	shard.SetQueryWatchdog(10*time.Millisecond, func(query string, args []interface{}) { stuckQueries <- query })
+	_, err := shard.Exec("UPDATE Person SET Name=? WHERE Id=?", "Al", 1)
+	assert(t, err == nil && len(stuckQueries) == 1 && <-stuckQueries == "UPDATE Person SET Name=? WHERE Id=?")
+
+	fake.delay = 0
+	_, err = shard.Exec("UPDATE Person SET Name=? WHERE Id=?", "Al", 1)
+	time.Sleep(20 * time.Millisecond)
+	assert(t, err == nil && len(stuckQueries) == 0)
+}
+
+type Status int8
+
+type numbers struct {
+	Small  int8
+	Status Status
+	Tiny   uint8
+	Big    int64
+	Price  float64
+	Ratio  float32
+}
+
+// scanColumn scans value into the output struct's field with the same name as column
+func scanColumn(output interface{}, column string, value string) errs.Err {
+	rawBytes := sql.RawBytes(value)
+	field := reflect.ValueOf(output).Elem().FieldByName(column)
+	return (&Shard{}).scanColumnValue(column, field, &rawBytes, "SELECT "+column, nil)
+}
+
+func TestScanIntOverflow(t *testing.T) {
+	var nums numbers
+	assert(t, scanColumn(&nums, "Small", "127") == nil)
+	assert(t, nums.Small == 127)
+	assert(t, scanColumn(&nums, "Small", "128") != nil)
+	assert(t, scanColumn(&nums, "Small", "-129") != nil)
+	assert(t, scanColumn(&nums, "Status", "3") == nil)
+	assert(t, nums.Status == Status(3))
+	assert(t, scanColumn(&nums, "Status", "300") != nil)
+	assert(t, scanColumn(&nums, "Tiny", "256") != nil)
+	assert(t, scanColumn(&nums, "Big", "9223372036854775807") == nil)
+	assert(t, nums.Big == 9223372036854775807)
+}
+
+func TestScanFloat(t *testing.T) {
+	var nums numbers
+	assert(t, scanColumn(&nums, "Price", "12.50") == nil)
+	assert(t, nums.Price == 12.5)
+	assert(t, scanColumn(&nums, "Ratio", "-1e-3") == nil)
+	assert(t, nums.Ratio == float32(-0.001))
+	assert(t, scanColumn(&nums, "Price", "twelve") != nil)
+	assert(t, scanColumn(&nums, "Ratio", "1e39") != nil)
+}
+
+func TestSelect(t *testing.T) {
+	shard := newFakeShard("TestSelect", threePeople)
+	var people []*person
+	assert(t, shard.Select(&people, "SELECT Id, Name FROM Person") == nil)
+	assert(t, len(people) == 3)
+	assert(t, people[2].Id == 3 && people[2].Name == "Cat")
+}
+
+func TestSelectRowsErr(t *testing.T) {
+	shard := newFakeShard("TestSelectRowsErr", &fakeDB{
+		columns:  threePeople.columns,
+		rows:     threePeople.rows,
+		errOnRow: 3,
+	})
+	var people []*person
+	assert(t, shard.Select(&people, "SELECT Id, Name FROM Person") != nil)
+
+	shard = newFakeShard("TestSelectOneRowsErr", &fakeDB{
+		columns:  threePeople.columns,
+		rows:     threePeople.rows,
+		errOnRow: 1,
+	})
+	var onePerson *person
+	found, err := shard.SelectMaybe(&onePerson, "SELECT Id, Name FROM Person WHERE Id=?", 1)
+	assert(t, err != nil && !found)
+}
+
+func TestSelectOneMaybe(t *testing.T) {
+	var alice *person
+	found, err := newFakeShard("TestSelectOneMaybe", &fakeDB{columns: threePeople.columns, rows: threePeople.rows[:1]}).SelectOneMaybe(&alice, "SELECT Id, Name FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && found && alice.Name == "Alice")
+	var nobody *person
+	found, err = newFakeShard("TestSelectOneMaybeNone", &fakeDB{columns: threePeople.columns}).SelectOneMaybe(&nobody, "SELECT Id, Name FROM Person WHERE Id=?", 4)
+	assert(t, err == nil && !found && nobody == nil)
+}
+
+func TestSelectErrorsReleaseConnections(t *testing.T) {
+	shard := newFakeShard("TestSelectErrorsReleaseConnections", &fakeDB{
+		columns: []string{"Id", "Name"},
+		rows:    [][]driver.Value{{"not-a-number", "Alice"}},
+	})
+	shard.db.SetMaxOpenConns(1)
+	shard.SetAcquireTimeout(time.Second)
+	for i := 0; i < 5; i++ {
+		var people []*person
+		assert(t, shard.Select(&people, "SELECT Id, Name FROM Person") != nil)
+		var onePerson *person
+		assert(t, shard.SelectOne(&onePerson, "SELECT Id, Name FROM Person WHERE Id=?", 1) != nil)
+	}
+	// Borrowed connections are returned asynchronously once their rows are closed
+	for i := 0; i < 100 && shard.db.Stats().InUse > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert(t, shard.db.Stats().InUse == 0)
+}
+
+type fakeTracer struct {
+	spans []string
+	errs  []error
+}
+
+func (tr *fakeTracer) StartSpan(ctx context.Context, query string) (context.Context, func(err error)) {
+	tr.spans = append(tr.spans, query)
+	return ctx, func(err error) { tr.errs = append(tr.errs, err) }
+}
+
+func TestTracer(t *testing.T) {
+	shard := newFakeShard("TestTracer", threePeople)
+	tracer := &fakeTracer{}
+	shard.SetTracer(tracer)
+	var people []*person
+	assert(t, shard.Select(&people, "SELECT Id, Name FROM Person") == nil)
+	_, err := shard.Exec("DELETE FROM Person WHERE Id=?", 1)
+	assert(t, err == nil)
+	assert(t, reflect.DeepEqual(tracer.spans, []string{"SELECT Id, Name FROM Person", "DELETE FROM Person WHERE Id=?"}))
+	assert(t, len(tracer.errs) == 2 && tracer.errs[0] == nil && tracer.errs[1] == nil)
+
+	// The driver cannot convert channel args
+	_, err = shard.Exec("DELETE FROM Person WHERE Id=?", make(chan int))
+	assert(t, err != nil && len(tracer.errs) == 3 && tracer.errs[2] != nil)
+}
+
+type taggedPerson struct {
+	Id     int64
+	Tags   []string `fun:"tags,csv"`
+	Scores []int    `fun:"scores,csv"`
+	Pets   []string `fun:"pets,json"`
+}
+
+func TestSelectTaggedSlices(t *testing.T) {
+	shard := newFakeShard("TestSelectTaggedSlices", &fakeDB{
+		columns: []string{"Id", "tags", "scores", "pets"},
+		rows:    [][]driver.Value{{"1", "a,b", "1, 2,3", `["cat","dog"]`}, {"2", "", "", nil}},
+	})
+	var people []*taggedPerson
+	assert(t, shard.Select(&people, "SELECT Id, tags, scores, pets FROM Person") == nil)
+	assert(t, reflect.DeepEqual(people[0].Tags, []string{"a", "b"}))
+	assert(t, reflect.DeepEqual(people[0].Scores, []int{1, 2, 3}))
+	assert(t, reflect.DeepEqual(people[0].Pets, []string{"cat", "dog"}))
+	assert(t, len(people[1].Tags) == 0 && len(people[1].Scores) == 0 && people[1].Pets == nil)
+
+	shard = newFakeShard("TestSelectBadTaggedSlices", &fakeDB{
+		columns: []string{"Id", "scores"},
+		rows:    [][]driver.Value{{"1", "1,x"}},
+	})
+	var badPeople []*taggedPerson
+	assert(t, shard.Select(&badPeople, "SELECT Id, scores FROM Person") != nil)
+}
+
+type post struct {
+	Id      int64
+	Body    string
+	Summary string `fun:"Summary,truncate"`
+}
+
+func TestMaxColumnBytes(t *testing.T) {
+	shard := newFakeShard("TestMaxColumnBytes", &fakeDB{
+		columns: []string{"Id", "Body", "Summary"},
+		rows:    [][]driver.Value{{"1", "Hello", "Hello there"}},
+	})
+	shard.SetMaxColumnBytes(5, false)
+	var posts []*post
+	assert(t, shard.Select(&posts, "SELECT Id, Body, Summary FROM Post") == nil)
+	assert(t, posts[0].Body == "Hello" && posts[0].Summary == "Hello")
+	shard.SetMaxColumnBytes(4, false)
+	var tooLongPosts []*post
+	assert(t, shard.Select(&tooLongPosts, "SELECT Id, Body, Summary FROM Post") != nil)
+	shard.SetMaxColumnBytes(4, true)
+	var truncatedPosts []*post
+	assert(t, shard.Select(&truncatedPosts, "SELECT Id, Body, Summary FROM Post") == nil)
+	assert(t, truncatedPosts[0].Body == "Hell")
+}
+
+func TestErrNotFound(t *testing.T) {
+	shard := newFakeShard("TestErrNotFound", &fakeDB{columns: []string{"Id", "Name"}})
+	var onePerson *person
+	err := shard.SelectOne(&onePerson, "SELECT Id, Name FROM Person WHERE Id=?", 1)
+	assert(t, errors.Is(err, ErrNotFound) && err.InternalInfo()["Query"] == "SELECT Id, Name FROM Person WHERE Id=?")
+	_, err = newFakeShard("TestErrNotFoundInt", &fakeDB{columns: []string{"Id"}}).SelectInt("SELECT Id FROM Person WHERE Id=?", 1)
+	assert(t, errors.Is(err, ErrNotFound))
+	_, err = newFakeShard("TestErrNotFoundTooMany", threePeople).SelectInt("SELECT Id FROM Person")
+	assert(t, !errors.Is(err, ErrNotFound))
+}
+
+func TestScanInterfaceValue(t *testing.T) {
+	var setting struct{ Value interface{} }
+	assert(t, scanColumn(&setting, "Value", "42") == nil)
+	assert(t, setting.Value == int64(42))
+	assert(t, scanColumn(&setting, "Value", "1.5") == nil)
+	assert(t, setting.Value == 1.5)
+	assert(t, scanColumn(&setting, "Value", "on") == nil)
+	assert(t, setting.Value == "on")
+}
+
+func TestCallbackPanicsReleaseConnections(t *testing.T) {
+	shard := newFakeShard("TestCallbackPanicsReleaseConnections", threePeople)
+	shard.db.SetMaxOpenConns(1)
+	callbacks := map[string]func(TxFunc) errs.Err{
+		"Transact":         shard.Transact,
+		"TransactRollback": shard.TransactRollback,
+		"Session":          shard.Session,
+	}
+	panicking := func(shard *Shard) errs.Err { panic("oops") }
+	for name, callback := range callbacks {
+		shard.SetRecoverPanics(true)
+		err := callback(panicking)
+		assert(t, err != nil)
+		assert(t, shard.db.Stats().InUse == 0)
+
+		shard.SetRecoverPanics(false)
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error(name + " did not re-panic")
+				}
+			}()
+			callback(panicking)
+		}()
+		assert(t, shard.db.Stats().InUse == 0)
+	}
+	assert(t, shard.Transact(func(shard *Shard) errs.Err { return errs.New(errs.Info{}) }) != nil)
+
+	shard.SetAcquireTimeout(time.Second)
+	shard.SetRecoverPanics(true)
+	err := SelectEach(shard, func(p *person) errs.Err { panic("oops") }, "SELECT Id, Name FROM Person")
+	assert(t, err != nil)
+	// Borrowed connections are returned asynchronously once their rows are closed
+	for i := 0; i < 100 && shard.db.Stats().InUse > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert(t, shard.db.Stats().InUse == 0)
+}
+
+func TestSelectEach(t *testing.T) {
+	shard := newFakeShard("TestSelectEach", &fakeDB{
+		columns: []string{"Id", "Nickname"},
+		rows:    [][]driver.Value{{"1", "Al"}, {"2", nil}, {"3", "Cat"}},
+	})
+	var nicknames []string
+	var rows []*nullablePerson
+	err := SelectEach(shard, func(p *nullablePerson) errs.Err {
+		rows = append(rows, p)
+		if p.Nickname == nil {
+			nicknames = append(nicknames, "")
+		} else {
+			nicknames = append(nicknames, *p.Nickname)
+		}
+		return nil
+	}, "SELECT Id, Nickname FROM Person")
+	assert(t, err == nil && reflect.DeepEqual(nicknames, []string{"Al", "", "Cat"}))
+	assert(t, rows[0] == rows[2]) // The row struct is reused
+
+	stopErr := errs.New(errs.Info{"Description": "stop"})
+	var ids []int64
+	err = SelectEach(shard, func(p *nullablePerson) errs.Err {
+		ids = append(ids, p.Id)
+		return stopErr
+	}, "SELECT Id, Nickname FROM Person")
+	assert(t, err == stopErr && len(ids) == 1)
+}
+
+func TestInsertBatchReturning(t *testing.T) {
+	// The fake db returns its rows for the @@auto_increment_increment query, and their count as RowsAffected
+	shard := newFakeShard("TestInsertBatchReturning", &fakeDB{
+		columns:      []string{"@@auto_increment_increment"},
+		rows:         [][]driver.Value{{"5"}},
+		lastInsertId: 11,
+	})
+	ids, err := shard.InsertBatchReturning("INSERT INTO Person (Name) VALUES (?)", "Alice")
+	assert(t, err == nil && len(ids) == 1 && ids[0] == 11)
+	assert(t, *shard.autoIncrement == 5)
+
+	shard = newFakeShard("TestInsertBatchReturningThree", &fakeDB{rows: threePeople.rows, lastInsertId: 11})
+	*shard.autoIncrement = 5
+	ids, err = shard.InsertBatchReturning("INSERT INTO Person (Name) VALUES (?), (?), (?)", "A", "B", "C")
+	assert(t, err == nil && len(ids) == 3 && ids[1] == 16 && ids[2] == 21)
+}
+
+func TestSelectCSV(t *testing.T) {
+	shard := newFakeShard("TestSelectCSV", &fakeDB{
+		columns: []string{"Id", "Name"},
+		rows:    [][]driver.Value{{"1", "Alice, Jr."}, {"2", nil}},
+	})
+	var buf bytes.Buffer
+	assert(t, shard.SelectCSV(&buf, "SELECT Id, Name FROM Person") == nil)
+	assert(t, buf.String() == "Id,Name\n1,\"Alice, Jr.\"\n2,\n")
+}
+
+func TestQueryOneErrors(t *testing.T) {
+	_, err := newFakeShard("TestQueryOneColumns", threePeople).SelectInt("SELECT Id, Name FROM Person WHERE Id=?", 1)
+	assert(t, err != nil && err.AllInfo()["Description"] == "Query returned more than one column")
+	_, err = newFakeShard("TestQueryOneRows", &fakeDB{columns: []string{"Id"}, rows: [][]driver.Value{{"1"}, {"2"}}}).SelectInt("SELECT Id FROM Person")
+	assert(t, err != nil && err.AllInfo()["Description"] == "Query returned more than one row")
+}
+
+type product struct {
+	Id    int64
+	Price int64 `fun:"Price,notnull"`
+	Stock int64
+}
+
+func TestStrictNull(t *testing.T) {
+	shard := newFakeShard("TestStrictNull", &fakeDB{
+		columns: []string{"Id", "Stock"},
+		rows:    [][]driver.Value{{"1", nil}},
+	})
+	var products []*product
+	assert(t, shard.Select(&products, "SELECT Id, Stock FROM Product") == nil)
+	shard.SetStrictNull(true)
+	var strictProducts []*product
+	assert(t, shard.Select(&strictProducts, "SELECT Id, Stock FROM Product") != nil)
+
+	shard = newFakeShard("TestNotNullTag", &fakeDB{
+		columns: []string{"Id", "Price"},
+		rows:    [][]driver.Value{{"1", nil}},
+	})
+	var taggedProducts []*product
+	assert(t, shard.Select(&taggedProducts, "SELECT Id, Price FROM Product") != nil)
+}
+
+type wideText struct {
+	A, B, C, D, E, F, G, H, I, J string
+}
+
+var wideTextDB = func() *fakeDB {
+	fake := &fakeDB{columns: []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}}
+	for i := 0; i < 1000; i++ {
+		fake.rows = append(fake.rows, []driver.Value{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"})
+	}
+	return fake
+}()
+
+func TestSelectAllStrings(t *testing.T) {
+	shard := newFakeShard("TestSelectAllStrings", &fakeDB{
+		columns: []string{"A", "B", "C"},
+		rows:    [][]driver.Value{{"a", nil, "c"}},
+	})
+	var texts []wideText
+	assert(t, shard.Select(&texts, "SELECT A, B, C FROM Text") == nil)
+	assert(t, texts[0].A == "a" && texts[0].B == "" && texts[0].C == "c")
+}
+
+func BenchmarkSelectAllStrings(b *testing.B) {
+	benchmarkSelectWideText(b, newFakeShard("BenchmarkSelectAllStrings", wideTextDB))
+}
+
+func BenchmarkSelectAllStringsGeneric(b *testing.B) {
+	shard := newFakeShard("BenchmarkSelectAllStringsGeneric", wideTextDB)
+	shard.SetMaxColumnBytes(1024, true) // Disables the all-strings fast path
+	benchmarkSelectWideText(b, shard)
+}
+
+func benchmarkSelectWideText(b *testing.B, shard *Shard) {
+	for i := 0; i < b.N; i++ {
+		var texts []*wideText
+		if err := shard.Select(&texts, "SELECT * FROM Text"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSelectOutputTypes(t *testing.T) {
+	shard := newFakeShard("TestSelectOutputTypes", threePeople)
+	var ids []*int64
+	assert(t, shard.Select(&ids, "SELECT Id FROM Person") != nil)
+	var maps []map[string]string
+	assert(t, shard.Select(&maps, "SELECT Id, Name FROM Person") != nil)
+	var people []person
+	assert(t, shard.Select(people, "SELECT Id, Name FROM Person") != nil)
+}
+
+func TestGet(t *testing.T) {
+	var alice person
+	found, err := newFakeShard("TestGet", &fakeDB{columns: threePeople.columns, rows: threePeople.rows[:1]}).Get(&alice, "SELECT Id, Name FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && found && alice.Id == 1 && alice.Name == "Alice")
+	var nobody person
+	found, err = newFakeShard("TestGetNone", &fakeDB{columns: threePeople.columns}).Get(&nobody, "SELECT Id, Name FROM Person WHERE Id=?", 4)
+	assert(t, err == nil && !found)
+	_, err = newFakeShard("TestGetBadOutput", threePeople).Get(nobody, "SELECT Id, Name FROM Person WHERE Id=?", 4)
+	assert(t, err != nil)
+}
+
+func TestActiveQueries(t *testing.T) {
+	shard := newFakeShard("TestActiveQueries", threePeople)
+	shard.SetAcquireTimeout(time.Second)
+	shard.SetTrackActiveQueries(true)
+	rows, err := shard.Query("SELECT Id, Name FROM Person")
+	assert(t, err == nil)
+	activeQueries := shard.ActiveQueries()
+	assert(t, len(activeQueries) == 1 && activeQueries[0].Query == "SELECT Id, Name FROM Person")
+	rows.Close()
+	assert(t, len(shard.ActiveQueries()) == 0 && shard.db.Stats().InUse == 0)
+	assert(t, rows.Close() == nil)
+
+	shard.SetAcquireTimeout(0)
+	rows, err = shard.Query("SELECT Id, Name FROM Person")
+	assert(t, err == nil && len(shard.ActiveQueries()) == 1)
+	rows.Close()
+	assert(t, len(shard.ActiveQueries()) == 0)
+}
+
+func TestTransact(t *testing.T) {
+	fake := &fakeDB{columns: threePeople.columns, rows: threePeople.rows}
+	shard := newFakeShard("TestTransact", fake)
+	err := shard.Transact(func(tx *Shard) errs.Err {
+		var people []*person
+		return tx.Select(&people, "SELECT Id, Name FROM Person")
+	})
+	assert(t, err == nil && fake.commits == 1 && fake.rollbacks == 0)
+
+	txErr := errs.New(errs.Info{"Description": "Insufficient funds"})
+	err = shard.Transact(func(tx *Shard) errs.Err { return txErr })
+	assert(t, err == txErr && fake.commits == 1 && fake.rollbacks == 1)
+}
+
+func TestQueryContext(t *testing.T) {
+	shard := newFakeShard("TestQueryContext", threePeople)
+	var people []*person
+	assert(t, shard.SelectContext(context.Background(), &people, "SELECT Id, Name FROM Person") == nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var cancelledPeople []*person
+	err := shard.SelectContext(ctx, &cancelledPeople, "SELECT Id, Name FROM Person")
+	assert(t, errors.Is(err, context.Canceled))
+	_, err = shard.ExecContext(ctx, "DELETE FROM Person")
+	assert(t, errors.Is(err, context.Canceled))
+}
+
+type snakePerson struct {
+	Id       int64  `db:"id"`
+	FullName string `db:"full_name"`
+	Cache    string `db:"-"`
+}
+
+func TestSelectDBTags(t *testing.T) {
+	shard := newFakeShard("TestSelectDBTags", &fakeDB{
+		columns: []string{"id", "full_name"},
+		rows:    [][]driver.Value{{"1", "Alice Smith"}},
+	})
+	var people []*snakePerson
+	assert(t, shard.Select(&people, "SELECT id, full_name FROM person") == nil)
+	assert(t, people[0].Id == 1 && people[0].FullName == "Alice Smith")
+	// Tagged fields don't match by their field name
+	assert(t, shard.findStructColumn(reflect.TypeOf(snakePerson{}), "FullName") == nil)
+	assert(t, shard.findStructColumn(reflect.TypeOf(snakePerson{}), "fullname") == nil)
+	assert(t, shard.findStructColumn(reflect.TypeOf(snakePerson{}), "full_name").field.Name == "FullName")
+}
+
+type snakeColumns struct {
+	UserId    int64
+	AvatarURL string
+	CreatedAt string
+}
+
+func TestSelectSnakeCaseColumns(t *testing.T) {
+	shard := newFakeShard("TestSelectSnakeCaseColumns", &fakeDB{
+		columns: []string{"user_id", "avatar_url", "created_at"},
+		rows:    [][]driver.Value{{"1", "http://a.b/c.png", "2020-01-01"}},
+	})
+	var rows []*snakeColumns
+	assert(t, shard.Select(&rows, "SELECT user_id, avatar_url, created_at FROM user") == nil)
+	assert(t, rows[0].UserId == 1 && rows[0].AvatarURL == "http://a.b/c.png" && rows[0].CreatedAt == "2020-01-01")
+}
+
+type nullablePerson struct {
+	Id       int64
+	Nickname *string
+	Age      *int64
+	Score    sql.NullInt64
+}
+
+func TestSelectNullPointerFields(t *testing.T) {
+	shard := newFakeShard("TestSelectNullPointerFields", &fakeDB{
+		columns: []string{"Id", "Nickname", "Age", "Score"},
+		rows:    [][]driver.Value{{"1", "Al", "0", "7"}, {"2", nil, nil, nil}},
+	})
+	var people []*nullablePerson
+	assert(t, shard.Select(&people, "SELECT Id, Nickname, Age, Score FROM Person") == nil)
+	assert(t, *people[0].Nickname == "Al" && *people[0].Age == 0 && people[0].Score.Valid && people[0].Score.Int64 == 7)
+	assert(t, people[1].Nickname == nil && people[1].Age == nil && !people[1].Score.Valid)
+}
+
+func TestScanTime(t *testing.T) {
+	var event struct {
+		At     time.Time
+		On     time.Time
+		Parsed time.Time
+		Zero   time.Time
+		Maybe  *time.Time
+	}
+	assert(t, scanColumn(&event, "At", "2020-03-04 05:06:07.5") == nil)
+	assert(t, event.At.Equal(time.Date(2020, 3, 4, 5, 6, 7, 5e8, time.UTC)))
+	assert(t, scanColumn(&event, "On", "2020-03-04") == nil)
+	assert(t, event.On.Equal(time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)))
+	assert(t, scanColumn(&event, "Parsed", "2020-03-04T05:06:07+02:00") == nil)
+	assert(t, event.Parsed.Equal(time.Date(2020, 3, 4, 3, 6, 7, 0, time.UTC)))
+	assert(t, scanColumn(&event, "Zero", "0000-00-00 00:00:00") == nil)
+	assert(t, event.Zero.IsZero())
+	assert(t, scanColumn(&event, "Maybe", "2020-03-04") == nil)
+	assert(t, event.Maybe != nil && event.Maybe.Equal(event.On))
+	assert(t, scanColumn(&event, "At", "yesterday") != nil)
+
+	shard := &Shard{}
+	shard.SetTimeLocation(time.FixedZone("UTC+2", 2*60*60))
+	rawBytes := sql.RawBytes("2020-03-04 05:06:07")
+	field := reflect.ValueOf(&event).Elem().FieldByName("At")
+	assert(t, shard.scanColumnValue("At", field, &rawBytes, "SELECT At", nil) == nil)
+	assert(t, event.At.Equal(time.Date(2020, 3, 4, 3, 6, 7, 0, time.UTC)))
+}
+
+func TestScanBool(t *testing.T) {
+	var flags struct{ Active bool }
+	for value, expected := range map[string]bool{"1": true, "0": false, "2": true, "-1": true, "true": true, "\x01": true, "\x00": false} {
+		assert(t, scanColumn(&flags, "Active", value) == nil)
+		assert(t, flags.Active == expected)
+	}
+	assert(t, scanColumn(&flags, "Active", "yes") != nil)
+}
+
+func TestScanBytes(t *testing.T) {
+	var blob struct {
+		Data []byte
+		UUID [4]byte
+	}
+	rawBytes := sql.RawBytes("\x00\x01\xff")
+	field := reflect.ValueOf(&blob).Elem().FieldByName("Data")
+	assert(t, (&Shard{}).scanColumnValue("Data", field, &rawBytes, "SELECT Data", nil) == nil)
+	rawBytes[0] = 'x' // RawBytes are reused by the driver, so scanned bytes must be copies
+	assert(t, bytes.Equal(blob.Data, []byte("\x00\x01\xff")))
+	assert(t, scanColumn(&blob, "UUID", "\x01\x02\x03\x04") == nil)
+	assert(t, blob.UUID == [4]byte{1, 2, 3, 4})
+	assert(t, scanColumn(&blob, "UUID", "\x01\x02") != nil)
+}
+
+func TestScanJSON(t *testing.T) {
+	var profile struct {
+		Address struct{ City string }
+		Labels  map[string]string
+		Scores  []int
+	}
+	assert(t, scanColumn(&profile, "Address", `{"City":"Oslo"}`) == nil)
+	assert(t, profile.Address.City == "Oslo")
+	assert(t, scanColumn(&profile, "Labels", `{"team":"core"}`) == nil)
+	assert(t, profile.Labels["team"] == "core")
+	assert(t, scanColumn(&profile, "Scores", `[1,2]`) == nil)
+	assert(t, len(profile.Scores) == 2 && profile.Scores[1] == 2)
+	assert(t, scanColumn(&profile, "Scores", `1,2`) != nil)
+}
+
+// userId scans ids stored as "u-123" strings
+type userId int64
+
+func (id *userId) Scan(src interface{}) error {
+	bytes, ok := src.([]byte)
+	if !ok || !strings.HasPrefix(string(bytes), "u-") {
+		return errors.New("bad user id")
+	}
+	num, stdErr := strconv.ParseInt(string(bytes[2:]), 10, 64)
+	*id = userId(num)
+	return stdErr
+}
+
+func TestScanScanner(t *testing.T) {
+	var row struct {
+		Owner    userId
+		Editor   *userId
+		Reviewed sql.NullTime
+	}
+	assert(t, scanColumn(&row, "Owner", "u-12") == nil)
+	assert(t, row.Owner == 12)
+	assert(t, scanColumn(&row, "Editor", "u-7") == nil)
+	assert(t, row.Editor != nil && *row.Editor == 7)
+	assert(t, scanColumn(&row, "Owner", "12") != nil)
+	assert(t, scanColumn(&row, "Reviewed", "2020-03-04 05:06:07") == nil)
+	assert(t, row.Reviewed.Valid && row.Reviewed.Time.Year() == 2020)
+}
+
+// money is a custom arg type which serializes itself, as cents
+type money struct{ cents int64 }
+
+func (m money) Value() (driver.Value, error) { return m.cents, nil }
+
+type name string
+
+func TestFixArgs(t *testing.T) {
+	emptyStr := ""
+	args := []interface{}{money{150}, &money{250}, name("Al"), name(""), &emptyStr, (*string)(nil)}
+	fixArgs(args)
+	assert(t, args[0] == money{150})
+	assert(t, *args[1].(*money) == money{250})
+	assert(t, args[2] == "Al" && args[3] == nil && args[4] == "" && args[5] == nil)
+
+	type timestamp time.Time
+	now := time.Now()
+	args = []interface{}{Status(3), uint8(4), float32(1.5), flag(true), timestamp(now), now}
+	fixArgs(args)
+	assert(t, args[0] == int64(3) && args[1] == uint64(4) && args[2] == float64(1.5) && args[3] == true)
+	assert(t, args[4].(time.Time).Equal(now) && args[5].(time.Time).Equal(now))
+}
+
+type flag bool
+
+func TestSelectGenerics(t *testing.T) {
+	people, err := SelectT[person](newFakeShard("TestSelectT", threePeople), "SELECT Id, Name FROM Person")
+	assert(t, err == nil && len(people) == 3 && people[1].Name == "Bob")
+	ids, err := SelectT[int64](newFakeShard("TestSelectTIds", &fakeDB{columns: []string{"Id"}, rows: [][]driver.Value{{"1"}, {"2"}}}), "SELECT Id FROM Person")
+	assert(t, err == nil && len(ids) == 2 && ids[1] == 2)
+
+	onePerson := &fakeDB{columns: threePeople.columns, rows: threePeople.rows[:1]}
+	alice, found, err := SelectOneT[*person](newFakeShard("TestSelectOneTPtr", onePerson), "SELECT Id, Name FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && found && alice.Name == "Alice")
+	aliceVal, found, err := SelectOneT[person](newFakeShard("TestSelectOneT", onePerson), "SELECT Id, Name FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && found && aliceVal.Name == "Alice")
+	_, found, err = SelectOneT[person](newFakeShard("TestSelectOneTNone", &fakeDB{columns: threePeople.columns}), "SELECT Id, Name FROM Person WHERE Id=?", 4)
+	assert(t, err == nil && !found)
+}
+
+func TestSelectInts(t *testing.T) {
+	shard := newFakeShard("TestSelectInts", &fakeDB{columns: []string{"Id"}, rows: [][]driver.Value{{"1"}, {"22"}}})
+	ids, err := shard.SelectInts("SELECT Id FROM Person")
+	assert(t, err == nil && len(ids) == 2 && ids[1] == 22)
+	strs, err := shard.SelectStrings("SELECT Id FROM Person")
+	assert(t, err == nil && len(strs) == 2 && strs[1] == "22")
+	_, err = newFakeShard("TestSelectIntsColumns", threePeople).SelectInts("SELECT Id, Name FROM Person")
+	assert(t, err != nil)
+}
+
+func TestSelectMaps(t *testing.T) {
+	shard := newFakeShard("TestSelectMaps", &fakeDB{
+		columns: []string{"Id", "Name"},
+		rows:    [][]driver.Value{{int64(1), "Alice"}, {int64(2), nil}},
+	})
+	maps, err := shard.SelectMaps("SELECT Id, Name FROM Person")
+	assert(t, err == nil && len(maps) == 2)
+	assert(t, maps[0]["Id"] == int64(1) && maps[0]["Name"] == "Alice" && maps[1]["Name"] == nil)
+	assert(t, mapValue("BIGINT", []byte("42")) == int64(42) && mapValue("UNSIGNED BIGINT", []byte("42")) == uint64(42))
+	assert(t, mapValue("DOUBLE", []byte("1.5")) == 1.5 && mapValue("DECIMAL", []byte("1.50")) == "1.50")
+}
+
+func TestSelectMapBy(t *testing.T) {
+	var peopleById map[int64]*person
+	assert(t, newFakeShard("TestSelectMapBy", threePeople).SelectMapBy(&peopleById, "Id", "SELECT Id, Name FROM Person") == nil)
+	assert(t, len(peopleById) == 3 && peopleById[2].Name == "Bob")
+
+	var snakePeopleById map[int64]snakePerson
+	shard := newFakeShard("TestSelectMapByColumn", &fakeDB{
+		columns: []string{"id", "full_name"},
+		rows:    [][]driver.Value{{"1", "Alice Smith"}, {"2", "Bob Jones"}},
+	})
+	assert(t, shard.SelectMapBy(&snakePeopleById, "id", "SELECT id, full_name FROM person") == nil)
+	assert(t, len(snakePeopleById) == 2 && snakePeopleById[2].FullName == "Bob Jones")
+	assert(t, shard.SelectMapBy(&snakePeopleById, "missing", "SELECT id, full_name FROM person") != nil)
+}
+
+func TestSelectScalar(t *testing.T) {
+	shard := newFakeShard("TestSelectScalar", &fakeDB{columns: []string{"At"}, rows: [][]driver.Value{{"2020-03-04 05:06:07"}}})
+	var at time.Time
+	found, err := shard.SelectScalar(&at, "SELECT MAX(At) FROM Event")
+	assert(t, err == nil && found && at.Year() == 2020)
+	price, found, err := SelectScalarT[float64](newFakeShard("TestSelectScalarT", &fakeDB{columns: []string{"Price"}, rows: [][]driver.Value{{"9.5"}}}), "SELECT MAX(Price) FROM Product")
+	assert(t, err == nil && found && price == 9.5)
+	maybePrice, found, err := SelectScalarT[*float64](newFakeShard("TestSelectScalarNull", &fakeDB{columns: []string{"Price"}, rows: [][]driver.Value{{nil}}}), "SELECT MAX(Price) FROM Product")
+	assert(t, err == nil && found && maybePrice == nil)
+	_, found, err = SelectScalarT[bool](newFakeShard("TestSelectScalarNone", &fakeDB{columns: []string{"Active"}}), "SELECT Active FROM Product")
+	assert(t, err == nil && !found)
+	_, err = shard.SelectScalar(at, "SELECT MAX(At) FROM Event")
+	assert(t, err != nil)
+}
+
+func TestSelectIter(t *testing.T) {
+	it, err := newFakeShard("TestSelectIter", threePeople).SelectIter(&person{}, "SELECT Id, Name FROM Person")
+	assert(t, err == nil)
+	var names []string
+	for it.Next() {
+		names = append(names, it.Value().(*person).Name)
+	}
+	assert(t, it.Err() == nil && it.Close() == nil)
+	assert(t, reflect.DeepEqual(names, []string{"Alice", "Bob", "Cat"}))
+
+	it, err = newFakeShard("TestSelectIterErr", &fakeDB{
+		columns: []string{"Id", "Name"},
+		rows:    [][]driver.Value{{"1", "Alice"}, {"x", "Bob"}, {"3", "Cat"}},
+	}).SelectIter(&person{}, "SELECT Id, Name FROM Person")
+	assert(t, err == nil)
+	numRows := 0
+	for it.Next() {
+		numRows += 1
+		it.Value()
+	}
+	assert(t, numRows == 2 && it.Err() != nil)
+	it.Close()
+}
+
+func TestInsertStruct(t *testing.T) {
+	shard := newFakeShard("TestInsertStruct", &fakeDB{rows: threePeople.rows[:1], lastInsertId: 7})
+	newPerson := &person{Name: "Dan"}
+	id, err := shard.InsertStruct("Person", newPerson)
+	assert(t, err == nil && id == 7 && newPerson.Id == 7)
+
+	newSnakePerson := &snakePerson{FullName: "Eve Smith"}
+	_, err = shard.InsertStruct("person", newSnakePerson)
+	assert(t, err == nil && newSnakePerson.Id == 7)
+
+	_, err = shard.InsertStruct("Person", person{Name: "Fay"})
+	assert(t, err == nil)
+}
+
+type account struct {
+	AccountNo string `fun:"account_no,pk"`
+	Id        int64
+	Owner     string
+	Balance   int64
+}
+
+func TestUpdateStruct(t *testing.T) {
+	// The fake db reports one affected row per row
+	fake := &fakeDB{rows: threePeople.rows[:1]}
+	shard := newFakeShard("TestUpdateStruct", fake)
+	assert(t, shard.UpdateStructByPK("Person", &person{Id: 1, Name: "Al"}) == nil)
+	assert(t, fake.lastExec == "UPDATE Person SET Name = ? WHERE Id = ?")
+	assert(t, shard.UpdateStructByPK("account", &account{AccountNo: "A1", Owner: "Al"}) == nil)
+	assert(t, fake.lastExec == "UPDATE account SET Id = ?, Owner = ?, Balance = ? WHERE account_no = ?")
+	assert(t, shard.UpdateStruct("account", &account{AccountNo: "A1", Owner: "Al"}) == nil)
+	assert(t, fake.lastExec == "UPDATE account SET Id = ?, Owner = ?, Balance = ? WHERE account_no = ?")
+	assert(t, shard.UpdateStruct("account", &account{Id: 2, Owner: "Al"}, "Id") == nil)
+	assert(t, fake.lastExec == "UPDATE account SET account_no = ?, Owner = ?, Balance = ? WHERE Id = ?")
+	assert(t, shard.UpdateStructFields("account", &account{AccountNo: "A1", Balance: 5}, "account_no", "Balance") == nil)
+	assert(t, fake.lastExec == "UPDATE account SET Balance = ? WHERE account_no = ?")
+	assert(t, shard.UpdateStructFields("snake", &snakePerson{Id: 1, FullName: "Al"}, "", "full_name") == nil)
+	assert(t, fake.lastExec == "UPDATE snake SET full_name = ? WHERE id = ?")
+	assert(t, shard.UpdateStructFields("snake", &snakePerson{Id: 1, FullName: "Al"}, "", "FullName") != nil)
+	assert(t, shard.UpdateStructFields("account", &account{AccountNo: "A1"}, "account_no") != nil)
+	assert(t, shard.UpdateStructByPK("Product", &struct{ Name string }{"Table"}) != nil)
+	assert(t, shard.UpdateStruct("Product", &struct{ Name string }{"Table"}) != nil)
+	assert(t, shard.UpdateStruct("account", &account{}, "account_no", "Id") != nil)
+}
+
+func TestUpsert(t *testing.T) {
+	fake := &fakeDB{rows: threePeople.rows[:1]}
+	shard := newFakeShard("TestUpsert", fake)
+	assert(t, shard.Upsert("Person", &person{Id: 1, Name: "Al"}) == nil)
+	assert(t, fake.lastExec == "INSERT INTO Person (Id, Name) VALUES (?, ?) ON DUPLICATE KEY UPDATE Name = VALUES(Name)")
+	shard.SetPlaceholderStyle(DollarPlaceholders)
+	assert(t, shard.Upsert("account", account{AccountNo: "A1", Id: 2, Owner: "Al", Balance: 5}) == nil)
+	assert(t, fake.lastExec == "INSERT INTO account (account_no, Id, Owner, Balance) VALUES ($1, $2, $3, $4)"+
+		" ON CONFLICT (account_no) DO UPDATE SET Id = EXCLUDED.Id, Owner = EXCLUDED.Owner, Balance = EXCLUDED.Balance")
+	assert(t, shard.Upsert("Product", &struct{ Name string }{"Table"}) != nil)
+}
+
+func TestInsertBatch(t *testing.T) {
+	fake := &fakeDB{}
+	shard := newFakeShard("TestInsertBatch", fake)
+	assert(t, shard.InsertBatch("Person", []person{{Name: "Al"}, {Name: "Bo"}}) == nil)
+	assert(t, fake.lastExec == "INSERT INTO Person (Name) VALUES (?), (?)")
+	assert(t, shard.InsertBatch("Person", []*person{{Id: 1, Name: "Al"}}) == nil)
+	assert(t, fake.lastExec == "INSERT INTO Person (Id, Name) VALUES (?, ?)")
+
+	shard.SetInsertBatchSize(2)
+	fake.lastExec = ""
+	assert(t, shard.InsertBatch("Person", []person{{Name: "Al"}, {Name: "Bo"}, {Name: "Cy"}}) == nil)
+	assert(t, fake.lastExec == "INSERT INTO Person (Name) VALUES (?)")
+
+	assert(t, shard.InsertBatch("Person", []person{{Name: "Al"}, {Id: 2, Name: "Bo"}}) != nil)
+	assert(t, shard.InsertBatch("Person", person{Name: "Al"}) != nil)
+	assert(t, shard.InsertBatch("Person", []person{}) == nil)
+}
+
+func TestInsertReturning(t *testing.T) {
+	fake := &fakeDB{columns: []string{"id"}, rows: [][]driver.Value{{int64(7)}}}
+	shard := newFakeShard("TestInsertReturning", fake)
+	shard.SetPlaceholderStyle(DollarPlaceholders)
+	var id int64
+	assert(t, shard.InsertReturning(&id, "INSERT INTO Person (Name) VALUES (?)", "Al") == nil && id == 7)
+	assert(t, fake.lastQuery == "INSERT INTO Person (Name) VALUES ($1) RETURNING id")
+	var key string
+	assert(t, shard.InsertReturning(&key, "INSERT INTO Person (Name) VALUES (?) returning Key", "Al") == nil && key == "7")
+	assert(t, fake.lastQuery == "INSERT INTO Person (Name) VALUES ($1) returning Key")
+	assert(t, shard.InsertReturning(&id, "INSERT INTO Person (Name) VALUES (?) ;\n", "Al") == nil)
+	assert(t, fake.lastQuery == "INSERT INTO Person (Name) VALUES ($1) RETURNING id")
+	assert(t, shard.InsertReturning(&id, "INSERT INTO Person (Name) /* returning */ VALUES ('Returning', ?)", "Al") == nil)
+	assert(t, fake.lastQuery == "INSERT INTO Person (Name) /* returning */ VALUES ('Returning', $1) RETURNING id")
+	assert(t, shard.InsertReturning(&id, "INSERT INTO Person (NotReturning) VALUES (?)", "Al") == nil)
+	assert(t, strings.HasSuffix(fake.lastQuery, " RETURNING id"))
+	fake.rows = nil
+	assert(t, shard.InsertReturning(&id, "INSERT INTO Person (Name) VALUES (?)", "Al") != nil)
+}
+
+func TestErrInfo(t *testing.T) {
+	shard := newFakeShard("TestErrInfo", threePeople)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := shard.ExecContext(ctx, "DELETE FROM Person WHERE Id=?", 1)
+	info := err.InternalInfo()
+	assert(t, info["Description"] == "Exec sqlConn.Exec() error" && info["Query"] == "DELETE FROM Person WHERE Id=?")
+	assert(t, reflect.DeepEqual(info["Args"], []interface{}{int64(1)}))
+	assert(t, err.UserMessage() == errs.DefaultUserMessage)
+
+	shard.SetErrInfoLimits(21, true)
+	info = shard.errInfo("Bad query", "SELECT * FROM Person WHERE Name='Zoë'", []interface{}{1})
+	assert(t, info["Query"] == "SELECT * FROM Person ..." && info["QueryLength"] == 38 && info["NumArgs"] == 1)
+	shard.SetErrInfoLimits(20, true) // Inside the two bytes of ë
+	info = shard.errInfo("Bad query", "SELECT Name FROM Zoës", nil)
+	assert(t, info["Query"] == "SELECT Name FROM Zo...")
+	_, hasArgs := info["Args"]
+	assert(t, !hasArgs)
+}
+
+func TestExists(t *testing.T) {
+	fake := &fakeDB{columns: []string{"Exists"}, rows: [][]driver.Value{{int64(1)}}}
+	shard := newFakeShard("TestExists", fake)
+	exists, err := shard.Exists("SELECT 1 FROM Person WHERE Id=?;", 1)
+	assert(t, err == nil && exists)
+	assert(t, fake.lastQuery == "SELECT EXISTS(SELECT 1 FROM Person WHERE Id=?)")
+	fake.rows = [][]driver.Value{{"0"}}
+	exists, err = shard.Exists("SELECT 1 FROM Person WHERE Id=?", 4)
+	assert(t, err == nil && !exists)
+	fake.rows = [][]driver.Value{{true}} // Postgres
+	exists, err = shard.Exists("SELECT 1 FROM Person WHERE Id=?", 1)
+	assert(t, err == nil && exists)
+}
+
+func TestDrainingTransactRollback(t *testing.T) {
+	shard := newFakeShard("TestDrainingTransactRollback", threePeople)
+	atomic.StoreInt32(&shard.draining, 1)
+	called := false
+	err := shard.TransactRollback(func(tx *Shard) errs.Err { called = true; return nil })
+	assert(t, err != nil && err.InternalInfo()["Description"] == "Shard is draining" && !called)
+	assert(t, shard.db.Stats().OpenConnections == 0)
+}
+
+func TestTransactAcquireTimeout(t *testing.T) {
+	shard := newFakeShard("TestTransactAcquireTimeout", threePeople)
+	shard.db.SetMaxOpenConns(1)
+	shard.SetAcquireTimeout(10 * time.Millisecond)
+	noop := func(shard *Shard) errs.Err { return nil }
+	err := shard.Session(func(session *Shard) errs.Err {
+		// The session holds the only connection
+		for _, callback := range []func(TxFunc) errs.Err{shard.Transact, shard.TransactRollback, shard.Session} {
+			err := callback(noop)
+			assert(t, err != nil && strings.HasPrefix(err.InternalInfo()["Description"].(string), "pool exhausted"))
+		}
+		// The caller's own deadline or cancelation is not reported as pool exhaustion
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err := shard.QueryContext(ctx, "SELECT Id, Name FROM Person")
+		assert(t, errors.Is(err, context.DeadlineExceeded) && err.InternalInfo()["Description"] == "Could not get connection")
+		cancel()
+		_, err = shard.QueryContext(ctx, "SELECT Id, Name FROM Person")
+		assert(t, err != nil && err.InternalInfo()["Description"] == "Could not get connection")
+		return nil
+	})
+	assert(t, err == nil)
+	assert(t, shard.Transact(noop) == nil && shard.db.Stats().InUse == 0)
+}
+
+func TestPeriodicPing(t *testing.T) {
+	shard := newFakeShard("TestPeriodicPing", threePeople)
+	assert(t, shard.db.Stats().OpenConnections == 0)
+	assert(t, shard.StartPeriodicPing(0, nil) != nil && shard.pinger.stop == nil)
+	err := shard.Transact(func(tx *Shard) errs.Err { return tx.StartPeriodicPing(time.Second, nil) })
+	assert(t, err != nil && err.InternalInfo()["Description"] == "StartPeriodicPing is not supported on transaction and session shards")
+	assert(t, shard.StartPeriodicPing(5*time.Millisecond, nil) == nil)
+	assert(t, shard.StartPeriodicPing(5*time.Millisecond, nil) == nil) // No-op while running
+	// The pings open a connection, which is kept idle
+	for i := 0; i < 100 && shard.db.Stats().OpenConnections == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert(t, shard.db.Stats().OpenConnections == 1 && shard.db.Stats().InUse == 0)
+	done := make(chan bool)
+	go func() { shard.stopPeriodicPing(); done <- true }()
+	assert(t, shard.Close() == nil)
+	<-done
+	assert(t, shard.pinger.stop == nil)
+}
+
+func TestPeriodicPingErrors(t *testing.T) {
+	shard := newFakeShard("TestPeriodicPingErrors", threePeople)
+	pingErrs := make(chan errs.Err, 1)
+	assert(t, shard.StartPeriodicPing(5*time.Millisecond, func(pinged *Shard, err errs.Err) {
+		select {
+		case pingErrs <- err:
+		default:
+		}
+	}) == nil)
+	shard.db.Close() // Makes every ping fail
+	err := <-pingErrs
+	assert(t, err.InternalInfo()["Description"] == "Periodic ping error" && err.InternalInfo()["DBName"] == "TestPeriodicPingErrors")
+	shard.stopPeriodicPing()
+}
+
+func TestUpdateBatch(t *testing.T) {
+	fake := &fakeDB{rows: threePeople.rows[:2]}
+	shard := newFakeShard("TestUpdateBatch", fake)
+	rowsAffected, err := shard.UpdateBatch("Person", "Id", map[interface{}]map[string]interface{}{
+		10: {"Name": "Jo"},
+		2:  {"Name": "Bo", "Age": 30},
+	})
+	assert(t, err == nil && rowsAffected == 2)
+	assert(t, fake.lastExec == "UPDATE Person SET"+
+		" Age = CASE Id WHEN ? THEN ? ELSE Age END,"+
+		" Name = CASE Id WHEN ? THEN ? WHEN ? THEN ? ELSE Name END"+
+		" WHERE Id IN (?, ?)")
+	assert(t, reflect.DeepEqual(fake.lastArgs, []driver.Value{
+		int64(2), int64(30), int64(2), "Bo", int64(10), "Jo", int64(2), int64(10)}))
+
+	_, err = shard.UpdateBatch("Person", "Id; DROP TABLE Person", map[interface{}]map[string]interface{}{1: {"Name": "Jo"}})
+	assert(t, err != nil)
+	rowsAffected, err = shard.UpdateBatch("Person", "Id", nil)
+	assert(t, err == nil && rowsAffected == 0)
+}
@@ -0,0 +1,110 @@
+package sql
+
+import (
+	"reflect"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// StructRows iterates over query results, scanning each row onto a new struct.
+// Create one with Shard.QueryStruct, and Close it when done.
+type StructRows struct {
+	rows       *Rows
+	structType reflect.Type
+	scanner    *rowScanner
+	query      string
+	args       []interface{}
+	scanErr    errs.Err // The first error of Value
+}
+
+// QueryStruct queries rows to be scanned onto structs of the same type as structVal,
+// which may be a struct or a struct pointer:
+//
+//	rows, err := shard.QueryStruct(Person{}, "SELECT Id, Name FROM Person")
+//	defer rows.Close()
+//	for rows.Next() {
+//		person, err := rows.Scan()
+//		... person.(*Person)
+//	}
+//	err = rows.Err()
+func (s *Shard) QueryStruct(structVal interface{}, query string, args ...interface{}) (*StructRows, errs.Err) {
+	structType := reflect.TypeOf(structVal)
+	if structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return nil, errs.New(s.errInfo("QueryStruct expects a struct or struct pointer", query, args))
+	}
+	rows, err := s.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	columns, stdErr := rows.Columns()
+	if stdErr != nil {
+		rows.Close()
+		return nil, errs.WrapWithInfo(stdErr, s.errInfo("QueryStruct rows.Columns error", query, args))
+	}
+	return &StructRows{rows, structType, s.newRowScanner(structType, columns), query, args, nil}, nil
+}
+
+// SelectIter is the same as QueryStruct. With Value, rows can be processed lazily:
+//
+//	it, err := shard.SelectIter(&Person{}, "SELECT Id, Name FROM Person")
+//	defer it.Close()
+//	for it.Next() {
+//		person := it.Value().(*Person)
+//	}
+//	err = it.Err()
+func (s *Shard) SelectIter(structVal interface{}, query string, args ...interface{}) (*StructRows, errs.Err) {
+	return s.QueryStruct(structVal, query, args...)
+}
+
+// Next prepares the next row for Scan or Value, and reports whether there is one.
+// It returns false after Value fails.
+func (r *StructRows) Next() bool {
+	return r.scanErr == nil && r.rows.Next()
+}
+
+// Scan returns a pointer to a new struct populated from the current row.
+func (r *StructRows) Scan() (interface{}, errs.Err) {
+	structPtrVal := reflect.New(r.structType)
+	err := r.scanInto(structPtrVal.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return structPtrVal.Interface(), nil
+}
+
+// Value returns a pointer to a new struct populated from the current row, like Scan.
+// If scanning fails it returns nil, stops iteration, and Err returns the error.
+func (r *StructRows) Value() interface{} {
+	structPtr, err := r.Scan()
+	if err != nil {
+		r.scanErr = err
+		return nil
+	}
+	return structPtr
+}
+
+// scanInto populates an existing struct from the current row
+func (r *StructRows) scanInto(structVal reflect.Value) errs.Err {
+	return r.scanner.scan(structVal, r.rows.Rows, r.query, r.args)
+}
+
+// Err returns any error that occurred while iterating.
+func (r *StructRows) Err() errs.Err {
+	if r.scanErr != nil {
+		return r.scanErr
+	}
+	if stdErr := r.rows.Err(); stdErr != nil {
+		return errs.WrapWithInfo(stdErr, r.scanner.shard.errInfo("StructRows rows.Err() error", r.query, r.args))
+	}
+	return nil
+}
+
+func (r *StructRows) Close() errs.Err {
+	if stdErr := r.rows.Close(); stdErr != nil {
+		return errs.WrapWithInfo(stdErr, r.scanner.shard.errInfo("StructRows rows.Close() error", r.query, r.args))
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package sql
+
+import (
+	"context"
+)
+
+// Tracer starts spans around queries for distributed tracing. It is defined here so
+// that this package does not depend on any particular tracing library, e.g OpenTelemetry.
+type Tracer interface {
+	// StartSpan is called before every query, with the query as the span's attribute.
+	// Finish is called when the query returns, with its error, if any.
+	StartSpan(ctx context.Context, query string) (spanCtx context.Context, finish func(err error))
+}
+
+// SetTracer sets the tracer which the shard starts a span with for every query.
+func (s *Shard) SetTracer(tracer Tracer) {
+	s.tracer = tracer
+}
+
+func (s *Shard) startSpan(ctx context.Context, query string) (context.Context, func(err error)) {
+	if s.tracer == nil {
+		return ctx, func(error) {}
+	}
+	return s.tracer.StartSpan(ctx, query)
+}
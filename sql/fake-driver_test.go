@@ -0,0 +1,140 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fakeDriver serves canned results from the fakeDB registered under the DSN
+type fakeDriver struct{}
+
+type fakeDB struct {
+	columns      []string
+	rows         [][]driver.Value
+	errOnRow     int   // If positive, fetching this 1-indexed row errors
+	lastInsertId int64 // Returned by Exec results, along with the number of rows as RowsAffected
+	commits      int
+	rollbacks    int
+	lastExec     string         // The last query passed to Exec
+	lastArgs     []driver.Value // The args of the last Exec or Query
+	lastQuery    string         // The last query passed to Query
+	nextResults  []*fakeDB      // Further result sets after the columns and rows of this one
+	delay        time.Duration  // How long Query and Exec take
+}
+
+var (
+	fakeDBsMutex sync.Mutex
+	fakeDBs      = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("fun-fake", fakeDriver{})
+}
+
+// newFakeShard returns a shard whose queries all return the given rows
+func newFakeShard(name string, fake *fakeDB) *Shard {
+	fakeDBsMutex.Lock()
+	fakeDBs[name] = fake
+	fakeDBsMutex.Unlock()
+	db, _ := sql.Open("fun-fake", name)
+	return NewShard(name, db)
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMutex.Lock()
+	defer fakeDBsMutex.Unlock()
+	return &fakeConn{fakeDBs[name]}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn: Prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{c.db}, nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	time.Sleep(c.db.delay)
+	c.db.lastQuery = query
+	c.db.setLastArgs(args)
+	return &fakeRows{db: c.db}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	time.Sleep(c.db.delay)
+	c.db.lastExec = query
+	c.db.setLastArgs(args)
+	return fakeResult{c.db.lastInsertId, int64(len(c.db.rows))}, nil
+}
+
+func (db *fakeDB) setLastArgs(args []driver.NamedValue) {
+	db.lastArgs = make([]driver.Value, len(args))
+	for i, arg := range args {
+		db.lastArgs[i] = arg.Value
+	}
+}
+
+type fakeResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.lastInsertId, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx fakeTx) Commit() error   { tx.db.commits += 1; return nil }
+func (tx fakeTx) Rollback() error { tx.db.rollbacks += 1; return nil }
+
+type fakeRows struct {
+	db          *fakeDB
+	index       int
+	resultIndex int // Into db.nextResults, when past the first result set
+}
+
+// resultSet returns the fake db of the current result set
+func (r *fakeRows) resultSet() *fakeDB {
+	if r.resultIndex == 0 {
+		return r.db
+	}
+	return r.db.nextResults[r.resultIndex-1]
+}
+
+func (r *fakeRows) HasNextResultSet() bool { return r.resultIndex < len(r.db.nextResults) }
+
+func (r *fakeRows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.resultIndex += 1
+	r.index = 0
+	return nil
+}
+
+func (r *fakeRows) Columns() []string { return r.resultSet().columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	db := r.resultSet()
+	if r.index+1 == db.errOnRow {
+		return errors.New("fakeRows: connection lost fetching row " + strconv.Itoa(db.errOnRow))
+	}
+	if r.index == len(db.rows) {
+		return io.EOF
+	}
+	copy(dest, db.rows[r.index])
+	r.index += 1
+	return nil
+}
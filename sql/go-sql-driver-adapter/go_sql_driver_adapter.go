@@ -19,7 +19,7 @@ func goSqlDriverOpener(username, password, dbName, host string, port int, connVa
 		username, password, host, port, dbName, connVars.Join("&"))
 	db, stdErr := sql.Open("mysql", sourceString)
 	if stdErr != nil {
-		return nil, errs.Wrap(stdErr, errs.Info{})
+		return nil, errs.WrapWithInfo(stdErr, errs.Info{})
 	}
 	return db, nil
 }
@@ -0,0 +1,40 @@
+// Package go_sqlite3_adapter opens SQLite databases with github.com/mattn/go-sqlite3,
+// e.g in-memory databases for local development and tests.
+//
+// SQLite serializes writes, so shards use a single connection. With a shard set,
+// pass maxConns=1 to NewShardSet. The db name is the database file path.
+package go_sqlite3_adapter
+
+import (
+	"database/sql"
+
+	"github.com/marcuswestin/fun-go/errs"
+	funGoSql "github.com/marcuswestin/fun-go/sql"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	funGoSql.SetOpener(sqlite3Opener)
+}
+
+// OpenShard returns a shard of the SQLite database at path, or of a new in-memory
+// database if path is ":memory:".
+func OpenShard(path string) (*funGoSql.Shard, errs.Err) {
+	db, err := sqlite3Opener("", "", path, "", 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return funGoSql.NewShard(path, db), nil
+}
+
+func sqlite3Opener(username, password, dbName, host string, port int, connVars funGoSql.ConnVariables) (*sql.DB, errs.Err) {
+	db, stdErr := sql.Open("sqlite3", dbName)
+	if stdErr != nil {
+		return nil, errs.WrapWithInfo(stdErr, errs.Info{})
+	}
+	// Every connection to :memory: opens a separate database, so keep the one connection open
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	return db, nil
+}
@@ -0,0 +1,56 @@
+// Package lib_pq_adapter opens shard set connections to Postgres with github.com/lib/pq.
+// Postgres uses $1-style placeholders, so call shardSet.SetPlaceholderStyle(sql.DollarPlaceholders)
+// before Connect. The SSL mode defaults to "disable", and can be set with e.g
+// shardSet.SetConnVariables(sql.ConnVariables{"sslmode": "require"}). Other non-MySQL
+// connection variables are passed on to lib/pq, e.g "connect_timeout" or "application_name".
+//
+// Postgres has no per-session auto_increment_offset, so shards would generate colliding
+// ids. Opening shard sets with maxShards > 1 therefore fails.
+package lib_pq_adapter
+
+import (
+	"database/sql"
+	"net"
+	"net/url"
+	"strconv"
+
+	_ "github.com/lib/pq"
+	"github.com/marcuswestin/fun-go/errs"
+	funGoSql "github.com/marcuswestin/fun-go/sql"
+)
+
+func init() {
+	funGoSql.SetOpener(libPqOpener)
+}
+
+// mysqlConnVars are the connection variables set by ShardSet for MySQL, which lib/pq doesn't understand
+var mysqlConnVars = map[string]bool{
+	"autocommit": true, "clientFoundRows": true, "charset": true, "collation": true,
+	"auto_increment_increment": true, "auto_increment_offset": true, "sql_mode": true,
+}
+
+func libPqOpener(username, password, dbName, host string, port int, connVars funGoSql.ConnVariables) (*sql.DB, errs.Err) {
+	if increment := connVars["auto_increment_increment"]; increment != "" && increment != "1" {
+		return nil, errs.New(errs.Info{"Description": "Postgres shard sets must have maxShards 1, since shards can't offset their generated ids",
+			"DBName": dbName, "MaxShards": increment})
+	}
+	params := url.Values{"sslmode": {"disable"}}
+	for name, val := range connVars {
+		if !mysqlConnVars[name] {
+			params.Set(name, val)
+		}
+	}
+	// url.URL escapes the user, password and database name, e.g passwords with spaces or quotes
+	sourceURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(username, password),
+		Host:     net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:     "/" + dbName,
+		RawQuery: params.Encode(),
+	}
+	db, stdErr := sql.Open("postgres", sourceURL.String())
+	if stdErr != nil {
+		return nil, errs.WrapWithInfo(stdErr, errs.Info{"DBName": dbName})
+	}
+	return db, nil
+}
@@ -19,7 +19,7 @@ func mymysqlDriverOpener(username, password, dbName, host string, port int, conn
 		host, port, connVars.Join(","), dbName, username, password)
 	db, stdErr := sql.Open("mymysql", sourceString)
 	if stdErr != nil {
-		return nil, errs.Wrap(stdErr, errs.Info{})
+		return nil, errs.WrapWithInfo(stdErr, errs.Info{})
 	}
 	return db, nil
 }
@@ -0,0 +1,67 @@
+package sql
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// MySQL server error numbers, see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	errNumDuplicateEntry     = 1062
+	errNumDeadlock           = 1213
+	errNumNoReferencedRowOld = 1216
+	errNumRowIsReferencedOld = 1217
+	errNumRowIsReferenced    = 1451
+	errNumNoReferencedRow    = 1452
+)
+
+// IsDuplicateKey returns true if err was caused by a duplicate key on insert or update.
+func IsDuplicateKey(err error) bool {
+	return mysqlErrorNumber(err) == errNumDuplicateEntry
+}
+
+// IsForeignKeyViolation returns true if err was caused by a foreign key constraint,
+// either because the referenced row does not exist or because the row is referenced.
+func IsForeignKeyViolation(err error) bool {
+	switch mysqlErrorNumber(err) {
+	case errNumRowIsReferenced, errNumNoReferencedRow, errNumRowIsReferencedOld, errNumNoReferencedRowOld:
+		return true
+	}
+	return false
+}
+
+// IsDeadlock returns true if err was caused by a deadlock. The transaction has
+// been rolled back by the server, and can be retried.
+func IsDeadlock(err error) bool {
+	return mysqlErrorNumber(err) == errNumDeadlock
+}
+
+var errorNumberRegexp = regexp.MustCompile(`^Error (\d+)`)
+
+// mysqlErrorNumber walks err's chain for a driver error with a MySQL error number,
+// e.g go-sql-driver's MySQLError.Number or mymysql's Error.Code. Drivers are not
+// imported, so the number is found by field name, and lastly by the error message.
+// It returns 0 if no error number is found.
+func mysqlErrorNumber(err error) int {
+	for ; err != nil; err = errors.Unwrap(err) {
+		errVal := reflect.ValueOf(err)
+		if errVal.Kind() == reflect.Ptr && !errVal.IsNil() {
+			errVal = errVal.Elem()
+		}
+		if errVal.Kind() == reflect.Struct {
+			for _, fieldName := range []string{"Number", "Code"} {
+				field := errVal.FieldByName(fieldName)
+				if field.IsValid() && field.Kind() >= reflect.Uint && field.Kind() <= reflect.Uint64 {
+					return int(field.Uint())
+				}
+			}
+		}
+		if match := errorNumberRegexp.FindStringSubmatch(err.Error()); match != nil {
+			num, _ := strconv.Atoi(match[1])
+			return num
+		}
+	}
+	return 0
+}
@@ -3,16 +3,301 @@ package sql
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/marcuswestin/fun-go/errs"
 )
 
-func SelectAll(structVal interface{}) string {
+// Columns returns the column list for selecting into the given struct or struct pointer,
+// e.g "SELECT " + columns + " FROM Person" selects "Id, Name, CreatedAt".
+func Columns(structVal interface{}) (string, errs.Err) {
+	valType, err := structTypeOf(structVal, "Columns")
+	if err != nil {
+		return "", err
+	}
+	return columnList(valType), nil
+}
+
+// MustColumns is like Columns, but panics if structVal is not a struct or struct pointer,
+// e.g for package vars: var personColumns = sql.MustColumns(Person{})
+func MustColumns(structVal interface{}) string {
+	columns, err := Columns(structVal)
+	if err != nil {
+		panic(err)
+	}
+	return columns
+}
+
+// columnList returns the comma separated columns of a struct type
+func columnList(structType reflect.Type) string {
+	var columns []string
+	for _, column := range structColumns(structType) {
+		columns = append(columns, column.name)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// structTypeOf returns the struct type of a struct or struct pointer value
+func structTypeOf(structVal interface{}, funcName string) (reflect.Type, errs.Err) {
 	valType := reflect.TypeOf(structVal)
-	numFields := valType.NumField()
-	fields := make([]string, numFields)
-	for i := 0; i < numFields; i++ {
-		fields[i] = valType.Field(i).Name
+	if valType == nil || !isStructOrStructPtr(valType) {
+		return nil, errs.New(errs.Info{"Description": funcName + " expects a struct or struct pointer", "Type": fmt.Sprint(valType)})
+	}
+	if valType.Kind() == reflect.Ptr {
+		valType = valType.Elem()
+	}
+	return valType, nil
+}
+
+// ColumnNames maps struct field names to their column names.
+type ColumnNames map[string]string
+
+// ColumnsOf returns the column name of each field of the given struct or struct pointer,
+// according to the fields' tags, e.g:
+//
+//	var personCols = sql.MustColumnsOf(Person{})
+//	query := "SELECT * FROM Person WHERE " + personCols.MustGet("CreatedAt") + " > ?"
+//
+// Assign it to a package var so that misnamed fields panic as early as possible.
+func ColumnsOf(structVal interface{}) (ColumnNames, errs.Err) {
+	valType, err := structTypeOf(structVal, "ColumnsOf")
+	if err != nil {
+		return nil, err
+	}
+	columnNames := ColumnNames{}
+	for _, column := range structColumns(valType) {
+		columnNames[column.field.Name] = column.name
+	}
+	return columnNames, nil
+}
+
+// MustColumnsOf is like ColumnsOf, but panics if structVal is not a struct or struct pointer.
+func MustColumnsOf(structVal interface{}) ColumnNames {
+	columnNames, err := ColumnsOf(structVal)
+	if err != nil {
+		panic(err)
+	}
+	return columnNames
+}
+
+// Get returns the column name of the given field, or an error if there is no such field.
+func (c ColumnNames) Get(field string) (string, errs.Err) {
+	column, found := c[field]
+	if !found {
+		return "", errs.New(errs.Info{"Description": "ColumnNames has no column for field", "Field": field})
+	}
+	return column, nil
+}
+
+// MustGet is like Get, but panics if there is no such field.
+func (c ColumnNames) MustGet(field string) string {
+	column, err := c.Get(field)
+	if err != nil {
+		panic(err)
+	}
+	return column
+}
+
+// structColumn is a struct field which maps to a column. Fields can be tagged with
+// `fun:"column_name,option,..."` to set their column name and scanning options, e.g
+// `fun:"tags,csv"` or `fun:",json"`. Fields tagged `fun:"-"` are skipped. The `db`
+// tag is used the same way for fields without a `fun` tag, e.g `db:"created_at"`.
+type structColumn struct {
+	name   string
+	tagged bool // Whether the name comes from the field's tag
+	field  reflect.StructField
+	opts   map[string]bool
+}
+
+// structColumns returns the columns of a struct type's exported fields.
+func structColumns(structType reflect.Type) (columns []structColumn) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" || isSkippedField(field) {
+			continue
+		}
+		columns = append(columns, newStructColumn(field))
 	}
-	fmt.Println("HERE", strings.Join(fields, ", "))
-	return strings.Join(fields, ", ")
+	return
+}
+
+func newStructColumn(field reflect.StructField) structColumn {
+	column := structColumn{name: field.Name, field: field, opts: map[string]bool{}}
+	tagParts := strings.Split(columnTag(field), ",")
+	if tagParts[0] != "" {
+		column.name = tagParts[0]
+		column.tagged = true
+	}
+	for _, opt := range tagParts[1:] {
+		column.opts[opt] = true
+	}
+	return column
+}
+
+func isSkippedField(field reflect.StructField) bool {
+	return columnTag(field) == "-"
+}
+
+// columnTag returns the field's `fun` tag, or its `db` tag if it has no `fun` tag
+func columnTag(field reflect.StructField) string {
+	if tag, found := field.Tag.Lookup("fun"); found {
+		return tag
+	}
+	return field.Tag.Get("db")
+}
+
+// Deprecated: use Columns
+func SelectAll(structVal interface{}) string {
+	return MustColumns(structVal)
+}
+
+// Initialisms are kept upper case by CamelCaseColumn, e.g user_id -> UserID
+var Initialisms = map[string]bool{
+	"ID": true, "UID": true, "UUID": true, "URL": true, "URI": true, "HTTP": true,
+	"HTTPS": true, "API": true, "JSON": true, "HTML": true, "SQL": true, "IP": true,
+}
+
+// CamelCaseColumn converts snake_case column names to CamelCase, e.g created_at -> CreatedAt.
+// Column names without underscores only get their first letter upper cased.
+func CamelCaseColumn(column string) string {
+	parts := strings.Split(column, "_")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		if upper := strings.ToUpper(part); Initialisms[upper] {
+			parts[i] = upper
+		} else {
+			parts[i] = upper[:1] + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// placeholderIndexes returns the byte indexes of all ? placeholders in the query,
+// skipping any inside quotes and comments, see unquotedIndexes.
+func placeholderIndexes(query string, style PlaceholderStyle) []int {
+	return unquotedIndexes(query, style, func(i int) bool { return query[i] == '?' })
+}
+
+// unquotedIndexes returns the byte indexes of the query for which match returns true,
+// skipping any inside quoted string literals and identifiers, and inside comments:
+// -- to the end of the line, and /* */. QuestionPlaceholders queries are quoted
+// MySQL-style, so # also comments to the end of the line and \ escapes characters
+// in quotes. With DollarPlaceholders, i.e Postgres, # is XOR and \ is a plain character.
+func unquotedIndexes(query string, style PlaceholderStyle, match func(i int) bool) (indexes []int) {
+	mysqlQuoting := style == QuestionPlaceholders
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote == 0 && (c == '#' && mysqlQuoting || strings.HasPrefix(query[i:], "--")):
+			if end := strings.IndexByte(query[i:], '\n'); end == -1 {
+				i = len(query)
+			} else {
+				i += end
+			}
+		case quote == 0 && strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end == -1 {
+				i = len(query)
+			} else {
+				i += 2 + end + 1
+			}
+		case quote != 0 && c == '\\' && quote != '`' && mysqlQuoting:
+			i += 1 // Skip escaped character
+		case quote != 0 && c == quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i += 1 // Doubled quote, e.g 'it''s'
+			} else {
+				quote = 0
+			}
+		case quote != 0:
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case match(i):
+			indexes = append(indexes, i)
+		}
+	}
+	return
+}
+
+type PlaceholderStyle int
+
+const (
+	QuestionPlaceholders PlaceholderStyle = iota // ?, e.g for MySQL and SQLite
+	DollarPlaceholders                           // $1, $2, ..., e.g for Postgres
+)
+
+// prepareQuery checks that the query has one placeholder per arg, and translates
+// its ? placeholders to the shard's placeholder style.
+func (s *Shard) prepareQuery(query string, args []interface{}) (string, errs.Err) {
+	indexes := placeholderIndexes(query, s.placeholders)
+	if len(indexes) != len(args) {
+		return query, errs.New(s.errInfo(fmt.Sprintf("Query has %d placeholders but got %d args", len(indexes), len(args)), query, args))
+	}
+	if s.placeholders == QuestionPlaceholders || len(indexes) == 0 {
+		return query, nil
+	}
+	var translated strings.Builder
+	prevIndex := 0
+	for i, index := range indexes {
+		translated.WriteString(query[prevIndex:index])
+		translated.WriteString("$" + strconv.Itoa(i+1))
+		prevIndex = index + 1
+	}
+	translated.WriteString(query[prevIndex:])
+	return translated.String(), nil
+}
+
+// BuildWhere builds a WHERE clause and its args from a map of column names to values,
+// e.g {"Name": "Alice", "Age": []int{30, 31}, "DeletedAt": nil} gives
+// "Age IN (?, ?) AND DeletedAt IS NULL AND Name = ?" and [30, 31, "Alice"].
+// Columns are sorted for a deterministic clause. No filters gives "1 = 1".
+// It errors if a column name is not a plain identifier.
+func BuildWhere(filters map[string]interface{}) (clause string, args []interface{}, err errs.Err) {
+	if len(filters) == 0 {
+		return "1 = 1", nil, nil
+	}
+	columns := make([]string, 0, len(filters))
+	for column := range filters {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	if err = checkIdentifiers(columns); err != nil {
+		return "", nil, err
+	}
+
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		value := filters[column]
+		vValue := reflect.ValueOf(value)
+		switch {
+		case value == nil:
+			conditions[i] = column + " IS NULL"
+		case vValue.Kind() == reflect.Slice && vValue.Type().Elem().Kind() != reflect.Uint8:
+			if vValue.Len() == 0 {
+				conditions[i] = "1 = 0" // Nothing is IN an empty list
+				continue
+			}
+			conditions[i] = column + " IN (" + placeholders(vValue.Len()) + ")"
+			for j := 0; j < vValue.Len(); j++ {
+				args = append(args, vValue.Index(j).Interface())
+			}
+		default:
+			conditions[i] = column + " = ?"
+			args = append(args, value)
+		}
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards % and _, and the \ escape character, so that
+// user input matches literally, e.g "name LIKE ?" with "%" + EscapeLike(term) + "%".
+func EscapeLike(str string) string {
+	return likeEscaper.Replace(str)
 }
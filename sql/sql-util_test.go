@@ -0,0 +1,155 @@
+package sql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+func TestPlaceholderIndexes(t *testing.T) {
+	assert(t, len(placeholderIndexes("SELECT * FROM Person", QuestionPlaceholders)) == 0)
+	assert(t, len(placeholderIndexes("SELECT * FROM Person WHERE Id=? AND Name=?", QuestionPlaceholders)) == 2)
+	assert(t, len(placeholderIndexes("SELECT '?' FROM Person WHERE Id=?", QuestionPlaceholders)) == 1)
+	assert(t, len(placeholderIndexes(`SELECT "?", '\'?' FROM Person WHERE Id=?`, QuestionPlaceholders)) == 1)
+	assert(t, len(placeholderIndexes("SELECT 'it''s?' FROM `Per?son` WHERE Id=?", QuestionPlaceholders)) == 1)
+	indexes := placeholderIndexes("Id=? AND Name=?", QuestionPlaceholders)
+	assert(t, indexes[0] == 3 && indexes[1] == 14)
+}
+
+func TestPlaceholderIndexesComments(t *testing.T) {
+	tests := []struct {
+		query   string
+		indexes []int
+	}{
+		{"SELECT * FROM Person -- Id=?\nWHERE Id=?", []int{38}},
+		{"SELECT * FROM Person # Id=?\nWHERE Id=?", []int{37}},
+		{"SELECT * FROM Person WHERE Id=? -- AND Name=?", []int{30}},
+		{"SELECT * /* Id=? */ FROM Person WHERE Id=?", []int{41}},
+		{"SELECT * /* Id=?\n*/ FROM Person WHERE Id=? /* AND Name=?", []int{41}},
+		{"SELECT '-- ?', '/* ?', '#?' FROM Person WHERE Id=?", []int{49}},
+		{"SELECT * FROM Person WHERE Age=?-1 /**/ AND Name=?", []int{31, 49}},
+	}
+	for _, test := range tests {
+		if indexes := placeholderIndexes(test.query, QuestionPlaceholders); !reflect.DeepEqual(indexes, test.indexes) {
+			t.Errorf("placeholderIndexes(%q) = %v, want %v", test.query, indexes, test.indexes)
+		}
+	}
+	postgres := &Shard{placeholders: DollarPlaceholders}
+	query, err := postgres.prepareQuery("SELECT * /* Id=? */ FROM Person WHERE Id=? -- AND Name=?", []interface{}{1})
+	assert(t, err == nil && query == "SELECT * /* Id=? */ FROM Person WHERE Id=$1 -- AND Name=?")
+}
+
+func TestPlaceholderIndexesPostgres(t *testing.T) {
+	// # is XOR, not a comment
+	assert(t, len(placeholderIndexes("SELECT a # b FROM T WHERE Id=?", QuestionPlaceholders)) == 0)
+	assert(t, reflect.DeepEqual(placeholderIndexes("SELECT a # b FROM T WHERE Id=?", DollarPlaceholders), []int{29}))
+	// \ does not escape the closing quote
+	assert(t, len(placeholderIndexes(`SELECT 'x\' FROM T WHERE Id=?`, QuestionPlaceholders)) == 0)
+	assert(t, reflect.DeepEqual(placeholderIndexes(`SELECT 'x\' FROM T WHERE Id=?`, DollarPlaceholders), []int{28}))
+	postgres := &Shard{placeholders: DollarPlaceholders}
+	query, err := postgres.prepareQuery(`SELECT 'x\', a # b FROM T WHERE Id=?`, []interface{}{1})
+	assert(t, err == nil && query == `SELECT 'x\', a # b FROM T WHERE Id=$1`)
+}
+
+func TestPrepareQuery(t *testing.T) {
+	mysql := &Shard{}
+	_, err := mysql.prepareQuery("SELECT * FROM Person WHERE Id=?", []interface{}{1})
+	assert(t, err == nil)
+	_, err = mysql.prepareQuery("SELECT * FROM Person WHERE Id=?", nil)
+	assert(t, err != nil)
+	_, err = mysql.prepareQuery("SELECT * FROM Person", []interface{}{1})
+	assert(t, err != nil)
+	postgres := &Shard{placeholders: DollarPlaceholders}
+	query, err := postgres.prepareQuery("SELECT '?' FROM Person WHERE Id=? AND Name=?", []interface{}{1, "A"})
+	assert(t, err == nil)
+	assert(t, query == "SELECT '?' FROM Person WHERE Id=$1 AND Name=$2")
+}
+
+func TestBuildWhere(t *testing.T) {
+	clause, args, err := BuildWhere(map[string]interface{}{"Name": "Alice", "Age": []int{30, 31}, "DeletedAt": nil})
+	assert(t, err == nil && clause == "Age IN (?, ?) AND DeletedAt IS NULL AND Name = ?")
+	assert(t, len(args) == 3 && args[0] == 30 && args[1] == 31 && args[2] == "Alice")
+	clause, args, err = BuildWhere(nil)
+	assert(t, err == nil && clause == "1 = 1" && len(args) == 0)
+	clause, _, err = BuildWhere(map[string]interface{}{"Id": []int64{}})
+	assert(t, err == nil && clause == "1 = 0")
+	_, _, err = BuildWhere(map[string]interface{}{"Id = 1 OR 1": 1})
+	assert(t, err != nil && err.InternalInfo()["Name"] == "Id = 1 OR 1")
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert(t, EscapeLike("50% off_sale") == `50\% off\_sale`)
+	assert(t, EscapeLike(`C:\dir`) == `C:\\dir`)
+}
+
+func TestQueryBuilder(t *testing.T) {
+	query, args := Select("Id", "Name").From("Person").Where("Age > ?", 30).Where("Name LIKE ? OR Name = ?", "A%", "Bob").OrderBy("Name", "Id DESC").Limit(10).Build()
+	assert(t, query == "SELECT Id, Name FROM Person WHERE (Age > ?) AND (Name LIKE ? OR Name = ?) ORDER BY Name, Id DESC LIMIT 10")
+	assert(t, len(args) == 3 && args[0] == 30 && args[2] == "Bob")
+	query, args = Select().From("Person").Build()
+	assert(t, query == "SELECT * FROM Person" && len(args) == 0)
+}
+
+type fakeMySQLError struct{ Number uint16 }
+
+func (e *fakeMySQLError) Error() string { return "fake mysql error" }
+
+func TestErrorNumbers(t *testing.T) {
+	assert(t, IsDuplicateKey(errs.WrapWithInfo(&fakeMySQLError{1062}, errs.Info{})))
+	assert(t, !IsDuplicateKey(errs.WrapWithInfo(&fakeMySQLError{1213}, errs.Info{})))
+	assert(t, IsDeadlock(&fakeMySQLError{1213}))
+	assert(t, IsForeignKeyViolation(errors.New("Error 1452: Cannot add or update a child row")))
+	assert(t, !IsForeignKeyViolation(errors.New("Error 1062: Duplicate entry")))
+	assert(t, !IsDuplicateKey(nil))
+}
+
+func TestColumnsOf(t *testing.T) {
+	type account struct {
+		Id        int64
+		CreatedAt string `fun:"created_at"`
+		Cache     string `fun:"-"`
+	}
+	columnNames, err := ColumnsOf(&account{})
+	assert(t, err == nil && len(columnNames) == 2)
+	column, err := columnNames.Get("CreatedAt")
+	assert(t, err == nil && column == "created_at")
+	_, err = columnNames.Get("Cache")
+	assert(t, err != nil)
+	_, err = ColumnsOf(1)
+	assert(t, err != nil)
+	assert(t, MustColumnsOf(account{}).MustGet("Id") == "Id")
+	defer func() { assert(t, recover() != nil) }()
+	columnNames.MustGet("Cache")
+}
+
+func TestDBTags(t *testing.T) {
+	type account struct {
+		Id        int64  `db:"id"`
+		CreatedAt string `db:"created_at"`
+		Cache     string `db:"-"`
+		Name      string `db:"name" fun:"display_name"`
+	}
+	columns, err := Columns(account{})
+	assert(t, err == nil && columns == "id, created_at, display_name")
+}
+
+func TestColumns(t *testing.T) {
+	columns, err := Columns(&person{})
+	assert(t, err == nil && columns == "Id, Name")
+	_, err = Columns([]person{})
+	assert(t, err != nil)
+	_, err = Columns(nil)
+	assert(t, err != nil)
+	assert(t, MustColumns(person{}) == "Id, Name")
+	defer func() { assert(t, recover() != nil) }()
+	MustColumns("Id, Name")
+}
+
+func assert(t *testing.T, shouldBeTrue bool) {
+	if shouldBeTrue {
+		return
+	}
+	t.Error("assert failed")
+}
@@ -9,7 +9,7 @@ import (
 func Open(path string) (file *os.File, err errs.Err) {
 	file, stdErr := os.Open(path)
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errs.Info{"Path": path})
+		err = errs.WrapWithInfo(stdErr, errs.Info{"Path": path})
 		return
 	}
 	return
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"net/http"
+
+	"github.com/marcuswestin/fun-go/errs"
+)
+
+// GetJSON gets url and decodes the JSON response body into a T. The response is
+// returned for header inspection, and non-2xx responses result in an error.
+func GetJSON[T any](url string) (result T, res *http.Response, err errs.Err) {
+	return decodeJSONResponse[T](send("GET", url, "", nil))
+}
+
+// PostJSON posts jsonPayload to url and decodes the JSON response body into a T.
+// See GetJSON.
+func PostJSON[T any](url string, jsonPayload interface{}) (result T, res *http.Response, err errs.Err) {
+	jsonReader, err := JSONReader(jsonPayload)
+	if err != nil {
+		return
+	}
+	return decodeJSONResponse[T](send("POST", url, "application/json", jsonReader))
+}
+
+func decodeJSONResponse[T any](res *http.Response, bodyBytes []byte, err errs.Err) (T, *http.Response, errs.Err) {
+	var result T
+	if err != nil {
+		return result, res, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return result, res, errs.New(errs.Info{"Description": "Unexpected response status",
+			"URL": res.Request.URL.String(), "StatusCode": res.StatusCode, "Body": string(bodyBytes)})
+	}
+	err = ParseJSONBytes(bodyBytes, &result)
+	return result, res, err
+}
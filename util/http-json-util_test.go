@@ -0,0 +1,44 @@
+package util
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type greeting struct {
+	Message string
+}
+
+func TestGetJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/greeting":
+			w.Header().Set("X-Request-Id", "42")
+			w.Write([]byte(`{"Message":"Hello"}`))
+		case "/echo":
+			io.Copy(w, r.Body)
+		case "/broken":
+			w.Write([]byte(`{"Message":`))
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	result, res, err := GetJSON[greeting](server.URL + "/greeting")
+	assert(t, err == nil && result.Message == "Hello" && res.Header.Get("X-Request-Id") == "42")
+	result, res, err = PostJSON[greeting](server.URL+"/echo", greeting{"Hi"})
+	assert(t, err == nil && result.Message == "Hi" && res.StatusCode == 200)
+
+	_, res, err = GetJSON[greeting](server.URL + "/missing")
+	assert(t, err != nil && res.StatusCode == 404 && err.InternalInfo()["StatusCode"] == 404)
+	assert(t, err.InternalInfo()["Body"] == "Not found\n")
+	_, _, err = GetJSON[greeting](server.URL + "/broken")
+	assert(t, err != nil)
+	_, res, err = GetJSON[greeting]("http://127.0.0.1:0/greeting")
+	assert(t, err != nil && res == nil)
+	_, _, err = PostJSON[greeting](server.URL+"/echo", func() {})
+	assert(t, err != nil)
+}
@@ -5,10 +5,27 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/marcuswestin/fun-go/errs"
 )
 
+// MaxResponseBytes limits how much of a response body is read. Larger responses
+// result in an error rather than being read into memory.
+var MaxResponseBytes int64 = 100 * 1024 * 1024
+
+// RequestLogger is called after every request made by the http helpers. Status is 0
+// if no response was received.
+type RequestLogger func(method, url string, status int, duration time.Duration, err error)
+
+var requestLogger RequestLogger
+
+// SetRequestLogger sets a function to log all requests made by the http helpers, e.g
+// with their timing and outcome. Nil disables it.
+func SetRequestLogger(logger RequestLogger) {
+	requestLogger = logger
+}
+
 func HTTPGet(url string) (statusCode int, body string, err errs.Err) {
 	return do("GET", url, "", nil)
 }
@@ -27,9 +44,23 @@ func HTTPPostString(url string, str string) (statusCode int, body string, err er
 }
 
 func do(method, url, contentType string, bodyReader io.Reader) (statusCode int, responseBody string, err errs.Err) {
-	req, stdErr := http.NewRequest("GET", url, bodyReader)
+	res, bodyBytes, err := send(method, url, contentType, bodyReader)
+	if res != nil {
+		statusCode = res.StatusCode
+	}
+	responseBody = string(bodyBytes)
+	return
+}
+
+// send makes a request and reads the whole response body. The returned response's body is closed.
+func send(method, url, contentType string, bodyReader io.Reader) (res *http.Response, bodyBytes []byte, err errs.Err) {
+	if logger := requestLogger; logger != nil {
+		start := time.Now()
+		defer func() { logRequest(logger, method, url, res, start, err) }()
+	}
+	req, stdErr := http.NewRequest(method, url, bodyReader)
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errs.Info{"URL": url})
+		err = errs.WrapWithInfo(stdErr, errs.Info{"URL": url})
 		return
 	}
 	if contentType != "" {
@@ -38,19 +69,66 @@ func do(method, url, contentType string, bodyReader io.Reader) (statusCode int,
 	req.Close = true
 	req.Header.Set("Connection", "close")
 
-	res, stdErr := http.DefaultClient.Do(req)
+	res, stdErr = http.DefaultClient.Do(req)
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errs.Info{"URL": url})
+		err = errs.WrapWithInfo(stdErr, errs.Info{"URL": url})
 		return
 	}
 	defer res.Body.Close()
 
-	statusCode = res.StatusCode
-	bodyBytes, stdErr := ioutil.ReadAll(res.Body)
+	bodyBytes, err = readBody(res.Body, url)
+	return
+}
+
+// HTTPDownload gets url and streams the response body to w, without buffering it in
+// memory. Non-2xx responses result in an error. It returns the number of bytes written,
+// which for partial downloads is included in the error info as "BytesWritten".
+func HTTPDownload(url string, w io.Writer) (bytesWritten int64, err errs.Err) {
+	var res *http.Response
+	if logger := requestLogger; logger != nil {
+		start := time.Now()
+		defer func() { logRequest(logger, "GET", url, res, start, err) }()
+	}
+	res, stdErr := http.Get(url)
 	if stdErr != nil {
-		err = errs.Wrap(stdErr, errs.Info{"URL": url})
+		err = errs.WrapWithInfo(stdErr, errs.Info{"URL": url})
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		err = errs.New(errs.Info{"Description": "Unexpected response status", "URL": url, "StatusCode": res.StatusCode})
+		return
+	}
+
+	bytesWritten, stdErr = io.Copy(w, res.Body)
+	if stdErr != nil {
+		err = errs.WrapWithInfo(stdErr, errs.Info{"Description": "Partial download", "URL": url, "BytesWritten": bytesWritten})
+		return
+	}
+	return
+}
+
+func readBody(body io.Reader, url string) (bodyBytes []byte, err errs.Err) {
+	bodyBytes, stdErr := ioutil.ReadAll(io.LimitReader(body, MaxResponseBytes+1))
+	if stdErr != nil {
+		err = errs.WrapWithInfo(stdErr, errs.Info{"URL": url})
+		return
+	}
+	if int64(len(bodyBytes)) > MaxResponseBytes {
+		err = errs.New(errs.Info{"Description": "Response body exceeds MaxResponseBytes", "URL": url, "MaxResponseBytes": MaxResponseBytes})
 		return
 	}
-	responseBody = string(bodyBytes)
 	return
 }
+
+func logRequest(logger RequestLogger, method, url string, res *http.Response, start time.Time, err errs.Err) {
+	var status int
+	if res != nil {
+		status = res.StatusCode
+	}
+	if err != nil {
+		logger(method, url, status, time.Since(start), err)
+	} else {
+		logger(method, url, status, time.Since(start), nil)
+	}
+}
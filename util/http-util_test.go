@@ -0,0 +1,90 @@
+package util
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+	defer func(maxResponseBytes int64) { MaxResponseBytes = maxResponseBytes }(MaxResponseBytes)
+
+	MaxResponseBytes = 10
+	status, body, err := HTTPGet(server.URL)
+	assert(t, err == nil && status == 200 && body == "0123456789")
+	MaxResponseBytes = 9
+	_, _, err = HTTPGet(server.URL)
+	assert(t, err != nil && err.InternalInfo()["Description"] == "Response body exceeds MaxResponseBytes")
+	_, _, err = HTTPPostString(server.URL, strings.Repeat("x", 100))
+	assert(t, err != nil)
+}
+
+func TestHTTPDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			w.Write([]byte("file contents"))
+		case "/partial":
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("file"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var file bytes.Buffer
+	bytesWritten, err := HTTPDownload(server.URL+"/file", &file)
+	assert(t, err == nil && bytesWritten == 13 && file.String() == "file contents")
+
+	var missingFile bytes.Buffer
+	bytesWritten, err = HTTPDownload(server.URL+"/missing", &missingFile)
+	assert(t, err != nil && err.InternalInfo()["StatusCode"] == 404 && bytesWritten == 0 && missingFile.Len() == 0)
+
+	var partialFile bytes.Buffer
+	bytesWritten, err = HTTPDownload(server.URL+"/partial", &partialFile)
+	assert(t, err != nil && err.InternalInfo()["Description"] == "Partial download")
+	assert(t, bytesWritten == 4 && err.InternalInfo()["BytesWritten"] == int64(4) && err.InternalInfo()["URL"] == server.URL+"/partial")
+}
+
+type loggedRequest struct {
+	method string
+	url    string
+	status int
+	err    error
+}
+
+func TestRequestLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	var logged []loggedRequest
+	SetRequestLogger(func(method, url string, status int, duration time.Duration, err error) {
+		logged = append(logged, loggedRequest{method, url, status, err})
+	})
+	defer SetRequestLogger(nil)
+
+	_, _, err := HTTPPostString(server.URL, "Hello")
+	assert(t, err == nil)
+	assert(t, len(logged) == 1 && logged[0] == loggedRequest{"POST", server.URL, http.StatusCreated, nil})
+	_, _, err = HTTPGet(server.URL)
+	assert(t, err == nil && len(logged) == 2 && logged[1].method == "GET" && logged[1].status == http.StatusCreated)
+
+	server.Close()
+	_, _, err = HTTPGet(server.URL)
+	assert(t, err != nil && len(logged) == 3 && logged[2].status == 0 && logged[2].err != nil)
+}
+
+func assert(t *testing.T, shouldBeTrue bool) {
+	if shouldBeTrue {
+		return
+	}
+	t.Error("assert failed")
+}